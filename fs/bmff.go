@@ -0,0 +1,120 @@
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// This file provides a minimal reader for the boxes of ISO base media files
+// (ISO/IEC 14496-12), the container of MP4, QuickTime, 3GP and HEIF files.
+// Boxes are located by their headers, so that only the payloads that are
+// needed are read, rather than the media data.
+
+// bmffMaxBoxes limits the number of boxes scanned within a single parent,
+// protecting the reader against corrupted or malicious files.
+const bmffMaxBoxes = 4096
+
+// errInvalidBMFF is returned for malformed ISO base media structures.
+var errInvalidBMFF = errors.New("invalid ISO base media structure")
+
+// bmffBox is a box of an ISO base media file.
+type bmffBox struct {
+	typ    string // four-character type, e.g. "moov"
+	offset int64  // offset of the payload
+	size   int64  // size of the payload
+}
+
+// readBoxes returns the boxes found between the offsets start and end of r.
+// The end of r is also the end of the boxes, so that end may be unknown.
+func readBoxes(r io.ReaderAt, start, end int64) ([]bmffBox, error) {
+	var boxes []bmffBox
+	err := scanBoxes(r, start, end, func(box bmffBox) bool {
+		boxes = append(boxes, box)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return boxes, nil
+}
+
+// scanBoxes calls fn for each box found between the offsets start and end of
+// r, in order, until fn returns false. Only the headers of the boxes are read,
+// and no more than bmffMaxBoxes of them.
+func scanBoxes(r io.ReaderAt, start, end int64, fn func(bmffBox) bool) error {
+	var header [16]byte
+	for offset, count := start, 0; offset+8 <= end; count++ {
+		if count == bmffMaxBoxes {
+			return errInvalidBMFF
+		}
+		n, err := r.ReadAt(header[:8], offset)
+		if n == 0 && errors.Is(err, io.EOF) {
+			break // end of a stream whose size is unknown
+		}
+		if err != nil && n < 8 {
+			return fmt.Errorf("%w: %w", errInvalidBMFF, err)
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0: // extends to the end of its parent
+			size = end - offset
+		case 1: // 64-bit size following the type
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return fmt.Errorf("%w: %w", errInvalidBMFF, err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize || size > end-offset {
+			return errInvalidBMFF
+		}
+		box := bmffBox{
+			typ:    string(header[4:8]),
+			offset: offset + headerSize,
+			size:   size - headerSize,
+		}
+		if !fn(box) {
+			break
+		}
+		offset += size
+	}
+	return nil
+}
+
+// findBox returns the box reached by following the path of box types from
+// the boxes found between the offsets start and end of r. The first box of
+// each type is followed, and the boxes after it are not read.
+func findBox(r io.ReaderAt, start, end int64, path ...string) (bmffBox, bool, error) {
+	box := bmffBox{offset: start, size: end - start}
+	for _, typ := range path {
+		found := false
+		err := scanBoxes(r, box.offset, box.offset+box.size, func(child bmffBox) bool {
+			if child.typ == typ {
+				box, found = child, true
+			}
+			return !found
+		})
+		if err != nil {
+			return bmffBox{}, false, err
+		}
+		if !found {
+			return bmffBox{}, false, nil
+		}
+	}
+	return box, true, nil
+}
+
+// readPayload reads the payload of box, which must not exceed max bytes.
+func readPayload(r io.ReaderAt, box bmffBox, max int64) ([]byte, error) {
+	if box.size > max {
+		return nil, errInvalidBMFF
+	}
+	data := make([]byte, box.size)
+	if _, err := r.ReadAt(data, box.offset); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidBMFF, err)
+	}
+	return data, nil
+}
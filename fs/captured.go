@@ -0,0 +1,276 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// This file provides a best-effort resolution of the time a photo, video or
+// recording was captured. File system times are usually wrong for media
+// copied between devices, so the embedded metadata, sidecar files and the
+// file name are tried first.
+
+// CaptureSource identifies where the capture time of a file was found.
+type CaptureSource uint8
+
+const (
+	CaptureSourceExif       CaptureSource = iota + 1 // EXIF metadata of a photo
+	CaptureSourceQuickTime                           // creation time of a QuickTime or MP4 movie
+	CaptureSourceID3                                 // recording date of an ID3 tag
+	CaptureSourceSidecar                             // XMP or JSON sidecar file
+	CaptureSourceTitle                               // date in the title of the file
+	CaptureSourceFileSystem                          // file system timestamps
+)
+
+// String returns the lower-case name of the source, e.g. "exif".
+func (s CaptureSource) String() string {
+	switch s {
+	case CaptureSourceExif:
+		return "exif"
+	case CaptureSourceQuickTime:
+		return "quicktime"
+	case CaptureSourceID3:
+		return "id3"
+	case CaptureSourceSidecar:
+		return "sidecar"
+	case CaptureSourceTitle:
+		return "title"
+	case CaptureSourceFileSystem:
+		return "filesystem"
+	default:
+		return fmt.Sprintf("CaptureSource(%d)", int(s))
+	}
+}
+
+// Confidence is the likelihood that a capture time is correct.
+type Confidence uint8
+
+const (
+	ConfidenceLow    Confidence = iota + 1 // a guess, such as a file system time, a date without a time or a year alone
+	ConfidenceMedium                       // recorded by a tool, or by the capturing device without the time of day
+	ConfidenceHigh                         // recorded by the capturing device
+)
+
+// String returns the lower-case name of the confidence level, e.g. "high".
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("Confidence(%d)", int(c))
+	}
+}
+
+// CapturedTime is the time a file was captured, with where it was found.
+type CapturedTime struct {
+	Time       time.Time
+	Source     CaptureSource
+	Confidence Confidence
+}
+
+// String returns the time with its source and confidence, e.g.
+// "2023-05-14T10:15:30+02:00 (exif, high)".
+func (c CapturedTime) String() string {
+	return fmt.Sprintf("%s (%s, %s)", c.Time.Format(time.RFC3339), c.Source, c.Confidence)
+}
+
+// sidecarMaxSize limits the size of the sidecar files read.
+const sidecarMaxSize = 1 << 20
+
+// CapturedAt returns the best estimate of the time the file described by info
+// was captured. The sources are tried in order: the embedded metadata (EXIF
+// for photos, the movie header for QuickTime and MP4 videos, the ID3 tag for
+// MP3 recordings), XMP and JSON sidecar files, a date in the title of the
+// file, and finally its file system times. Unreadable or implausible values
+// are skipped, so a result is always returned.
+//
+// Times without a recorded time zone are returned in the local time zone.
+func CapturedAt(info FileInfo) CapturedTime {
+	if !info.IsDir() {
+		if c, ok := embeddedCaptureTime(info); ok {
+			return c
+		}
+		if c, ok := sidecarCaptureTime(info); ok {
+			return c
+		}
+	}
+	if title, ok := ParseTitle(info.Title()); ok && plausibleCaptureTime(title.Time) {
+		c := CapturedTime{Time: title.Time, Source: CaptureSourceTitle, Confidence: ConfidenceMedium}
+		if !title.HasTime {
+			c.Confidence = ConfidenceLow
+		}
+		return c
+	}
+
+	// Copies usually get a new creation time, whereas tools copying media
+	// tend to preserve the modification time: the earliest is the best guess.
+	t := info.LastWriteTime()
+	if info.HasCreationTime() && info.CreationTime().Before(t) {
+		t = info.CreationTime()
+	}
+	return CapturedTime{Time: t, Source: CaptureSourceFileSystem, Confidence: ConfidenceLow}
+}
+
+// plausibleCaptureTime reports whether t may be a capture time. Devices
+// record unknown times as zeros, and clocks are sometimes wildly wrong.
+func plausibleCaptureTime(t time.Time) bool {
+	return t.Year() >= 1900 && t.Before(time.Now().Add(24*time.Hour))
+}
+
+// embeddedCaptureTime returns the capture time recorded in the metadata
+// embedded in the file, selected from its content type.
+func embeddedCaptureTime(info FileInfo) (CapturedTime, bool) {
+	ct, err := Sniff(info)
+	if err != nil {
+		return CapturedTime{}, false
+	}
+
+	switch ct.MIME {
+	case ContentTypeMP4.MIME, ContentTypeM4V.MIME, ContentTypeM4A.MIME, ContentType3GP.MIME, ContentTypeQuickTime.MIME:
+		m, err := info.Movie()
+		if err == nil && plausibleCaptureTime(m.CreationTime) {
+			return CapturedTime{Time: m.CreationTime, Source: CaptureSourceQuickTime, Confidence: ConfidenceHigh}, true
+		}
+	case ContentTypeMP3.MIME:
+		t, precision, err := id3Date(info.Abs())
+		if err == nil && plausibleCaptureTime(t) {
+			c := CapturedTime{Time: t, Source: CaptureSourceID3, Confidence: ConfidenceHigh}
+			switch precision {
+			case id3PrecisionDay:
+				c.Confidence = ConfidenceMedium
+			case id3PrecisionYear:
+				c.Confidence = ConfidenceLow
+			}
+			return c, true
+		}
+	default:
+		x, err := info.Exif()
+		if err != nil {
+			break
+		}
+		for _, t := range []time.Time{x.DateTimeOriginal, x.DateTimeDigitized} {
+			if plausibleCaptureTime(t) {
+				return CapturedTime{Time: t, Source: CaptureSourceExif, Confidence: ConfidenceHigh}, true
+			}
+		}
+		// The modification time is usually the capture time, unless the
+		// photo was edited.
+		if plausibleCaptureTime(x.DateTime) {
+			return CapturedTime{Time: x.DateTime, Source: CaptureSourceExif, Confidence: ConfidenceMedium}, true
+		}
+	}
+	return CapturedTime{}, false
+}
+
+// id3Date returns the recording date of the ID3 tag of the MP3 file at path.
+func id3Date(path string) (time.Time, id3Precision, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	defer f.Close()
+	return readID3Date(f)
+}
+
+// sidecarCaptureTime returns the capture time recorded in an XMP or JSON
+// sidecar file accompanying the file described by info, as written by photo
+// editors and by Google Takeout. Both "name.ext.xmp" and "name.xmp" are
+// tried.
+func sidecarCaptureTime(info FileInfo) (CapturedTime, bool) {
+	base := filepath.Join(filepath.Dir(info.Abs()), info.Title())
+	candidates := []string{
+		info.Abs() + ".xmp", info.Abs() + ".XMP", base + ".xmp", base + ".XMP",
+		info.Abs() + ".json", base + ".json",
+	}
+	for _, path := range candidates {
+		if path == info.Abs() {
+			continue
+		}
+		data, err := readSidecar(path)
+		if err != nil {
+			continue
+		}
+		var t time.Time
+		var ok bool
+		if filepath.Ext(path) == ".json" {
+			t, ok = takeoutTime(data)
+		} else {
+			t, ok = xmpTime(data)
+		}
+		if ok && plausibleCaptureTime(t) {
+			return CapturedTime{Time: t, Source: CaptureSourceSidecar, Confidence: ConfidenceMedium}, true
+		}
+	}
+	return CapturedTime{}, false
+}
+
+// readSidecar reads the sidecar file at path.
+func readSidecar(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, sidecarMaxSize))
+}
+
+// xmpDateProperties are the XMP properties holding the capture time, by
+// decreasing priority, matched as attributes or as elements.
+var xmpDateProperties = []*regexp.Regexp{
+	regexp.MustCompile(`exif:DateTimeOriginal(?:="([^"]*)"|>([^<]*)<)`),
+	regexp.MustCompile(`photoshop:DateCreated(?:="([^"]*)"|>([^<]*)<)`),
+	regexp.MustCompile(`xmp:CreateDate(?:="([^"]*)"|>([^<]*)<)`),
+}
+
+// xmpTimeLayouts are the layouts of the ISO 8601 dates used by XMP.
+var xmpTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+}
+
+// xmpTime returns the capture time recorded in an XMP packet.
+func xmpTime(data []byte) (time.Time, bool) {
+	for _, re := range xmpDateProperties {
+		m := re.FindSubmatch(data)
+		if m == nil {
+			continue
+		}
+		value := string(m[1]) + string(m[2])
+		for _, layout := range xmpTimeLayouts {
+			if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// takeoutTime returns the capture time recorded in a Google Takeout JSON
+// sidecar file.
+func takeoutTime(data []byte) (time.Time, bool) {
+	var metadata struct {
+		PhotoTakenTime struct {
+			Timestamp string `json:"timestamp"`
+		} `json:"photoTakenTime"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(metadata.PhotoTakenTime.Timestamp, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
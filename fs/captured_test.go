@@ -0,0 +1,223 @@
+package fs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildID3 builds an ID3v2 tag of the given version holding ISO-8859-1 text
+// frames, given as alternating identifiers and values.
+func buildID3(version byte, frames ...string) []byte {
+	var body []byte
+	for i := 0; i+1 < len(frames); i += 2 {
+		text := append([]byte{0}, frames[i+1]...)
+		body = append(body, frames[i]...)
+		switch version {
+		case 2:
+			body = append(body, 0, 0, byte(len(text)))
+		case 3:
+			body = binary.BigEndian.AppendUint32(body, uint32(len(text)))
+			body = append(body, 0, 0)
+		case 4:
+			body = append(body, 0, 0, 0, byte(len(text)), 0, 0) // syncsafe below 128
+		}
+		body = append(body, text...)
+	}
+	body = append(body, make([]byte, 16)...) // padding
+	b := []byte{'I', 'D', '3', version, 0, 0, 0, 0, 0, byte(len(body))}
+	return append(b, body...)
+}
+
+func TestCapturedAt(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	videoTime := time.Date(2023, 5, 14, 8, 15, 30, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		content    string
+		sidecars   map[string]string
+		expected   time.Time
+		source     CaptureSource
+		confidence Confidence
+	}{
+		{
+			name:       "photo.jpg",
+			content:    string(buildJPEG(testExifTIFF(), 16, 12)),
+			sidecars:   map[string]string{"photo.xmp": `<x exif:DateTimeOriginal="2019-08-01T12:00:00Z"/>`},
+			expected:   time.Date(2023, 5, 14, 10, 15, 30, 250e6, time.FixedZone("", 2*60*60)),
+			source:     CaptureSourceExif,
+			confidence: ConfidenceHigh,
+		},
+		{
+			name:       "IMG_0001.HEIC",
+			content:    string(buildHEIC(testExifTIFF(), 4032, 3024, 0, true)),
+			expected:   time.Date(2023, 5, 14, 10, 15, 30, 250e6, time.FixedZone("", 2*60*60)),
+			source:     CaptureSourceExif,
+			confidence: ConfidenceHigh,
+		},
+		{
+			name:       "clip.mp4",
+			content:    string(append(ftyp("isom", "isom", "mp41"), buildBox("moov", buildBox("mvhd", buildMvhd(videoTime, 1000, 5000)))...)),
+			expected:   videoTime,
+			source:     CaptureSourceQuickTime,
+			confidence: ConfidenceHigh,
+		},
+		{
+			name:       "clip-without-date.mov",
+			content:    string(append(ftyp("qt  ", "qt  "), buildBox("moov", buildBox("mvhd", make([]byte, 100)))...)),
+			expected:   modTime,
+			source:     CaptureSourceFileSystem,
+			confidence: ConfidenceLow,
+		},
+		{
+			name:       "song-v4.mp3",
+			content:    string(buildID3(4, "TIT2", "Song", "TDRC", "2021-07-04T18:30:00")),
+			expected:   time.Date(2021, 7, 4, 18, 30, 0, 0, time.Local),
+			source:     CaptureSourceID3,
+			confidence: ConfidenceHigh,
+		},
+		{
+			name:       "song-v3.mp3",
+			content:    string(buildID3(3, "TYER", "2021", "TDAT", "0407", "TIME", "1830")),
+			expected:   time.Date(2021, 7, 4, 18, 30, 0, 0, time.Local),
+			source:     CaptureSourceID3,
+			confidence: ConfidenceHigh,
+		},
+		{
+			name:       "song-v2.mp3",
+			content:    string(buildID3(2, "TYE", "2021", "TDA", "0407")),
+			expected:   time.Date(2021, 7, 4, 0, 0, 0, 0, time.Local),
+			source:     CaptureSourceID3,
+			confidence: ConfidenceMedium,
+		},
+		{
+			name:       "song-year.mp3",
+			content:    string(buildID3(4, "TDRC", "2021")),
+			expected:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local),
+			source:     CaptureSourceID3,
+			confidence: ConfidenceLow,
+		},
+		{
+			name:       "song-month.mp3",
+			content:    string(buildID3(4, "TDRC", "2021-07")),
+			expected:   time.Date(2021, 7, 1, 0, 0, 0, 0, time.Local),
+			source:     CaptureSourceID3,
+			confidence: ConfidenceLow,
+		},
+		{
+			name:       "song-tyer.mp3",
+			content:    string(buildID3(3, "TYER", "2021")),
+			expected:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local),
+			source:     CaptureSourceID3,
+			confidence: ConfidenceLow,
+		},
+		{
+			name:       "song-tye.mp3",
+			content:    string(buildID3(2, "TYE", "2021")),
+			expected:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local),
+			source:     CaptureSourceID3,
+			confidence: ConfidenceLow,
+		},
+		{
+			name:       "IMG_0001.CR2",
+			content:    "raw",
+			sidecars:   map[string]string{"IMG_0001.xmp": "<x>\n<exif:DateTimeOriginal>2019-08-01T12:00:00.5+01:00</exif:DateTimeOriginal>\n</x>"},
+			expected:   time.Date(2019, 8, 1, 11, 0, 0, 500e6, time.UTC),
+			source:     CaptureSourceSidecar,
+			confidence: ConfidenceMedium,
+		},
+		{
+			name:       "takeout.jpg",
+			content:    "not a photo",
+			sidecars:   map[string]string{"takeout.jpg.json": `{"title": "takeout.jpg", "photoTakenTime": {"timestamp": "1684052130"}}`},
+			expected:   time.Unix(1684052130, 0),
+			source:     CaptureSourceSidecar,
+			confidence: ConfidenceMedium,
+		},
+		{
+			name:       "IMG_20230514_101530.jpg",
+			content:    "not a photo",
+			expected:   time.Date(2023, 5, 14, 10, 15, 30, 0, time.Local),
+			source:     CaptureSourceTitle,
+			confidence: ConfidenceMedium,
+		},
+		{
+			name:       "IMG_0001 2021-05-03.jpg",
+			content:    "not a photo",
+			expected:   time.Date(2021, 5, 3, 0, 0, 0, 0, time.Local),
+			source:     CaptureSourceTitle,
+			confidence: ConfidenceLow,
+		},
+		{
+			name:       "Scan 2001-02-03.png",
+			content:    "not a photo",
+			expected:   time.Date(2001, 2, 3, 0, 0, 0, 0, time.Local),
+			source:     CaptureSourceTitle,
+			confidence: ConfidenceLow,
+		},
+		{
+			name:       "PXL_20230230_101530.jpg",
+			content:    "not a photo",
+			expected:   modTime,
+			source:     CaptureSourceFileSystem,
+			confidence: ConfidenceLow,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{tc.name: tc.content}
+			for name, content := range tc.sidecars {
+				files[name] = content
+			}
+			createTree(t, dir, files)
+			path := filepath.Join(dir, tc.name)
+			require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+			info, err := NewFileInfo(path)
+			require.NoError(t, err)
+			c := CapturedAt(info)
+			assert.Equal(t, tc.source, c.Source)
+			assert.Equal(t, tc.confidence, c.Confidence)
+			assert.True(t, tc.expected.Equal(c.Time), "expected %s, got %s", tc.expected, c.Time)
+		})
+	}
+}
+
+func TestCapturedAtDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "2022-12-25 Christmas")
+	require.NoError(t, os.Mkdir(dir, 0755))
+
+	info, err := NewFileInfo(dir)
+	require.NoError(t, err)
+	c := CapturedAt(info)
+	assert.Equal(t, CaptureSourceTitle, c.Source)
+	assert.Equal(t, ConfidenceLow, c.Confidence)
+	assert.Equal(t, time.Date(2022, 12, 25, 0, 0, 0, 0, time.Local), c.Time)
+}
+
+func TestDecodeID3Text(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"latin1", []byte("\x00caf\xE9\x00"), "café"},
+		{"utf16 little endian", []byte("\x01\xFF\xFE2\x000\x002\x001\x00\x00\x00"), "2021"},
+		{"utf16 big endian", []byte("\x02\x002\x000\x002\x001"), "2021"},
+		{"utf8", []byte("\x03caf\xC3\xA9"), "café"},
+		{"empty", nil, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, decodeID3Text(tc.data))
+		})
+	}
+}
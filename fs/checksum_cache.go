@@ -0,0 +1,150 @@
+package fs
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// This file provides a persistent cache of file digests, so that unchanged
+// files are not hashed again across runs. Digests are stored in extended
+// attributes of the files themselves, which follow the files when they are
+// moved or renamed, and in a sidecar file on file systems without extended
+// attribute support.
+
+// checksumXattrPrefix is the prefix of the extended attributes storing digests,
+// followed by the name of the algorithm, e.g. "user.checksum.sha256".
+const checksumXattrPrefix = "user.checksum."
+
+// ChecksumCache stores the digests of files together with the modification
+// time and the size of the files they were computed at. A cached digest is
+// only returned while the LastWriteTime and the Size of the file are unchanged.
+// It is safe for concurrent use.
+type ChecksumCache struct {
+	sidecar string
+
+	mu      sync.Mutex
+	entries map[string]map[string]string // records by absolute path and algorithm
+	dirty   bool
+}
+
+// NewChecksumCache creates a ChecksumCache. Digests are stored in the
+// extended attributes of the files when possible, and otherwise in the
+// sidecar JSON file at sidecarPath, which is loaded if it exists. The sidecar
+// file is only written by Save. If sidecarPath is empty, digests are only
+// stored in extended attributes.
+func NewChecksumCache(sidecarPath string) (*ChecksumCache, error) {
+	c := &ChecksumCache{entries: make(map[string]map[string]string)}
+	if sidecarPath == "" {
+		return c, nil
+	}
+
+	var err error
+	if c.sidecar, err = absolute(sidecarPath); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(c.sidecar)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("%s: invalid checksum cache: %w", c.sidecar, err)
+	}
+	return c, nil
+}
+
+// Get returns the digest of the file described by info computed with the
+// algorithm, if it is cached and the file did not change since.
+func (c *ChecksumCache) Get(info FileInfo, algorithm HashAlgorithm) (Digest, bool) {
+	if value, err := getxattr(info.Abs(), checksumXattrPrefix+algorithm.String()); err == nil {
+		if digest, ok := parseChecksum(string(value), info); ok {
+			return digest, true
+		}
+	}
+
+	c.mu.Lock()
+	record, ok := c.entries[info.Abs()][algorithm.String()]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return parseChecksum(record, info)
+}
+
+// Put stores the digest of the file described by info computed with the
+// algorithm. It is stored in an extended attribute of the file, or in the
+// sidecar file if the attribute cannot be set. An error is returned if the
+// attribute cannot be set and there is no sidecar file.
+func (c *ChecksumCache) Put(info FileInfo, algorithm HashAlgorithm, digest Digest) error {
+	record := formatChecksum(digest, info)
+	err := setxattr(info.Abs(), checksumXattrPrefix+algorithm.String(), []byte(record))
+	if err == nil || c.sidecar == "" {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[info.Abs()] == nil {
+		c.entries[info.Abs()] = make(map[string]string)
+	}
+	c.entries[info.Abs()][algorithm.String()] = record
+	c.dirty = true
+	return nil
+}
+
+// Save writes the digests stored in the sidecar file, if they changed since
+// the cache was created or last saved. The file is replaced atomically.
+func (c *ChecksumCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sidecar == "" || !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.sidecar, data, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// formatChecksum returns the record of a digest computed for the file
+// described by info: the hexadecimal digest, the modification time in
+// nanoseconds since the Unix epoch and the size, separated by spaces.
+func formatChecksum(digest Digest, info FileInfo) string {
+	return fmt.Sprintf("%s %d %d", digest, info.LastWriteTime().UnixNano(), info.Size())
+}
+
+// parseChecksum returns the digest of a record, if it was computed for the
+// current modification time and size of the file described by info.
+func parseChecksum(record string, info FileInfo) (Digest, bool) {
+	fields := strings.Fields(record)
+	if len(fields) != 3 {
+		return nil, false
+	}
+	modTime, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || modTime != info.LastWriteTime().UnixNano() {
+		return nil, false
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || size != info.Size() {
+		return nil, false
+	}
+	digest, err := hex.DecodeString(fields[0])
+	if err != nil || len(digest) == 0 {
+		return nil, false
+	}
+	return digest, true
+}
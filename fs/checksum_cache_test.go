@@ -0,0 +1,117 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumCache(t *testing.T) {
+	root := t.TempDir()
+	createTree(t, root, map[string]string{"a.jpg": "photo-a", "b.jpg": "photo-b"})
+	pathA := filepath.Join(root, "a.jpg")
+	digest := Digest{0xca, 0xfe}
+
+	t.Run("xattr", func(t *testing.T) {
+		cache, err := NewChecksumCache("")
+		require.NoError(t, err)
+		info, err := NewFileInfo(pathA)
+		require.NoError(t, err)
+
+		if err := cache.Put(info, HashSHA256, digest); errors.Is(err, ErrXattrUnsupported) {
+			t.Skip("Skipping xattr test because extended attributes are not supported")
+		} else {
+			require.NoError(t, err)
+		}
+
+		value, err := getxattr(pathA, "user.checksum.sha256")
+		require.NoError(t, err)
+		assert.Equal(t, formatChecksum(digest, info), string(value))
+
+		cached, ok := cache.Get(info, HashSHA256)
+		assert.True(t, ok)
+		assert.Equal(t, digest, cached)
+		_, ok = cache.Get(info, HashMD5)
+		assert.False(t, ok)
+
+		// A new cache finds the digest in the file itself.
+		other, err := NewChecksumCache("")
+		require.NoError(t, err)
+		_, ok = other.Get(info, HashSHA256)
+		assert.True(t, ok)
+
+		// The digest is invalidated when the file changes.
+		later := info.LastWriteTime().Add(time.Second)
+		require.NoError(t, os.Chtimes(pathA, later, later))
+		info, err = NewFileInfo(pathA)
+		require.NoError(t, err)
+		_, ok = cache.Get(info, HashSHA256)
+		assert.False(t, ok)
+	})
+
+	t.Run("sidecar", func(t *testing.T) {
+		sidecar := filepath.Join(root, "checksums.json")
+		cache, err := NewChecksumCache(sidecar)
+		require.NoError(t, err)
+		info, err := NewFileInfo(filepath.Join(root, "b.jpg"))
+		require.NoError(t, err)
+
+		// Store the digest as if the file system did not support extended attributes.
+		cache.entries[info.Abs()] = map[string]string{"md5": formatChecksum(digest, info)}
+		cache.dirty = true
+		require.NoError(t, cache.Save())
+
+		reloaded, err := NewChecksumCache(sidecar)
+		require.NoError(t, err)
+		cached, ok := reloaded.Get(info, HashMD5)
+		assert.True(t, ok)
+		assert.Equal(t, digest, cached)
+
+		require.NoError(t, os.WriteFile(sidecar, []byte("{"), 0644))
+		_, err = NewChecksumCache(sidecar)
+		assert.Error(t, err)
+	})
+
+	t.Run("records", func(t *testing.T) {
+		info, err := NewFileInfo(pathA)
+		require.NoError(t, err)
+		record := formatChecksum(digest, info)
+
+		parsed, ok := parseChecksum(record, info)
+		assert.True(t, ok)
+		assert.Equal(t, digest, parsed)
+
+		for _, invalid := range []string{"", "cafe", "cafe 1 2", "zz 1 7", record + " 1"} {
+			_, ok := parseChecksum(invalid, info)
+			assert.False(t, ok, invalid)
+		}
+	})
+}
+
+func TestHasherCache(t *testing.T) {
+	root := t.TempDir()
+	createTree(t, root, map[string]string{"a.jpg": "photo-a"})
+	info, err := NewFileInfo(filepath.Join(root, "a.jpg"))
+	require.NoError(t, err)
+
+	cache, err := NewChecksumCache(filepath.Join(root, "checksums.json"))
+	require.NoError(t, err)
+	h := &Hasher{Algorithms: []HashAlgorithm{HashSHA256, HashCRC32}, Cache: cache}
+
+	digests, err := h.Hash(context.Background(), info)
+	require.NoError(t, err)
+	assert.Equal(t, "3388d209", digests[HashCRC32].String())
+
+	// Cached digests are returned without reading the file again.
+	fake := Digest{0xca, 0xfe}
+	require.NoError(t, cache.Put(info, HashCRC32, fake))
+	digests, err = h.Hash(context.Background(), info)
+	require.NoError(t, err)
+	assert.Equal(t, fake, digests[HashCRC32])
+}
@@ -0,0 +1,384 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// This file provides a duplicate file finder. Candidates are narrowed down
+// in stages of increasing cost: files are first grouped by size, then by a
+// partial hash of their head and tail, then by a full content hash, and
+// optionally compared byte by byte. Each group of duplicates designates a
+// file to keep, selected by a pluggable policy.
+
+// defaultPartialSize is the number of bytes hashed at the head and at the
+// tail of files for the partial hash stage.
+const defaultPartialSize = 4096
+
+// KeeperPolicy selects the file to keep among a group of identical files.
+// It returns the index of the selected file in files, which is never empty.
+type KeeperPolicy func(files []FileInfo) int
+
+// KeepOldest is a KeeperPolicy selecting the file with the oldest creation
+// time. Ties are broken by path.
+func KeepOldest(files []FileInfo) int {
+	keep := 0
+	for i, f := range files[1:] {
+		k := files[keep]
+		if f.CreationTime().Before(k.CreationTime()) ||
+			(f.CreationTime().Equal(k.CreationTime()) && f.Abs() < k.Abs()) {
+			keep = i + 1
+		}
+	}
+	return keep
+}
+
+// KeepShortestPath is a KeeperPolicy selecting the file with the shortest
+// absolute path. Ties are broken by path.
+func KeepShortestPath(files []FileInfo) int {
+	keep := 0
+	for i, f := range files[1:] {
+		k := files[keep]
+		if len(f.Abs()) < len(k.Abs()) || (len(f.Abs()) == len(k.Abs()) && f.Abs() < k.Abs()) {
+			keep = i + 1
+		}
+	}
+	return keep
+}
+
+// KeepInDir returns a KeeperPolicy selecting a file located below the first
+// of the given directories that contains one, in order of preference. If
+// several files are located below the same directory, or if none of the
+// directories contains a file, the selection is delegated to fallback, or
+// to KeepOldest if fallback is nil.
+func KeepInDir(fallback KeeperPolicy, dirs ...string) KeeperPolicy {
+	if fallback == nil {
+		fallback = KeepOldest
+	}
+	return func(files []FileInfo) int {
+		for _, dir := range dirs {
+			if absDir, err := absolute(dir); err == nil {
+				dir = absDir
+			}
+			var indexes []int
+			for i, f := range files {
+				if isBelow(f.Abs(), dir) {
+					indexes = append(indexes, i)
+				}
+			}
+			if len(indexes) > 0 {
+				candidates := make([]FileInfo, len(indexes))
+				for i, index := range indexes {
+					candidates[i] = files[index]
+				}
+				return indexes[fallback(candidates)]
+			}
+		}
+		return fallback(files)
+	}
+}
+
+// isBelow reports whether path is located below dir.
+func isBelow(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// DuplicateOptions controls the behavior of FindDuplicates.
+type DuplicateOptions struct {
+	// WalkOptions selects which files are considered, with the same
+	// semantics as for Walk.
+	WalkOptions
+
+	// MinSize excludes files smaller than the given size, in bytes.
+	// Empty files are always excluded.
+	MinSize int64
+
+	// PartialSize is the number of bytes hashed at the head and at the tail
+	// of files to discard candidates before computing full hashes.
+	// A value of 0 or less uses 4 KiB.
+	PartialSize int64
+
+	// Algorithm is the algorithm used for the full content hash.
+	// The zero value uses SHA-256.
+	Algorithm HashAlgorithm
+
+	// ByteCompare makes the finder compare files byte by byte after the
+	// full hash stage, to rule out hash collisions.
+	ByteCompare bool
+
+	// Keeper selects the file to keep in each group. If nil, KeepOldest is used.
+	Keeper KeeperPolicy
+
+	// Workers is the number of files scanned and hashed concurrently.
+	// A value of 0 or less uses runtime.NumCPU().
+	Workers int
+}
+
+// DuplicateGroup is a set of files with identical content.
+type DuplicateGroup struct {
+	Size       int64      // size of each file
+	Digest     Digest     // full content hash of the files
+	Keep       FileInfo   // file selected by the keeper policy
+	Duplicates []FileInfo // other files, ordered by path
+}
+
+// FindDuplicates scans the tree rooted at root and returns the groups of
+// files with identical content, largest files first. Paths referring to the
+// same file through hard links are not reported as duplicates of each other.
+// Files that cannot be read are skipped. An error is only returned if root
+// cannot be scanned or ctx is cancelled.
+func FindDuplicates(ctx context.Context, root string, opts DuplicateOptions) ([]DuplicateGroup, error) {
+	result, err := Scan(ctx, root, ScanOptions{WalkOptions: opts.WalkOptions, Workers: opts.Workers, Ordered: true})
+	if err != nil {
+		return nil, err
+	}
+	return FindDuplicatesIn(ctx, result.Entries, opts)
+}
+
+// FindDuplicatesIn returns the groups of files with identical content among
+// files, largest files first. The WalkOptions of opts are ignored.
+func FindDuplicatesIn(ctx context.Context, files []FileInfo, opts DuplicateOptions) ([]DuplicateGroup, error) {
+	partialSize := opts.PartialSize
+	if partialSize <= 0 {
+		partialSize = defaultPartialSize
+	}
+	algorithm := opts.Algorithm
+	if algorithm == 0 {
+		algorithm = HashSHA256
+	}
+	keeper := opts.Keeper
+	if keeper == nil {
+		keeper = KeepOldest
+	}
+
+	// Stage 1: group regular files by size, ignoring additional hard links.
+	seen := make(map[FileID]bool)
+	bySize := make(map[int64][]FileInfo)
+	for _, f := range files {
+		if f.IsDir() || f.IsSymlink() || f.Size() == 0 || f.Size() < opts.MinSize {
+			continue
+		}
+		if id := f.FileID(); !id.IsZero() {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+		bySize[f.Size()] = append(bySize[f.Size()], f)
+	}
+	candidates := make([][]FileInfo, 0, len(bySize))
+	for _, group := range bySize {
+		if len(group) > 1 {
+			candidates = append(candidates, group)
+		}
+	}
+
+	// Stage 2: split the groups by a partial hash of the head and tail.
+	candidates, _, err := splitGroups(ctx, candidates, opts.Workers, func(f FileInfo) (string, error) {
+		digest, err := partialDigest(f.Abs(), f.Size(), partialSize)
+		return string(digest), err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Stage 3: split the groups by a full content hash.
+	hasher := &Hasher{Algorithms: []HashAlgorithm{algorithm}}
+	var fullKeys map[string]string
+	candidates, fullKeys, err = splitGroups(ctx, candidates, opts.Workers, func(f FileInfo) (string, error) {
+		digests, err := hasher.Hash(ctx, f)
+		return string(digests[algorithm]), err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Stage 4: optionally split the groups by comparing the files byte by byte.
+	if opts.ByteCompare {
+		candidates, err = splitByContent(ctx, candidates)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	groups := make([]DuplicateGroup, 0, len(candidates))
+	for _, group := range candidates {
+		sort.Slice(group, func(i, j int) bool {
+			return comparePaths(group[i].Abs(), group[j].Abs()) < 0
+		})
+		keep := keeper(group)
+		duplicates := make([]FileInfo, 0, len(group)-1)
+		duplicates = append(duplicates, group[:keep]...)
+		duplicates = append(duplicates, group[keep+1:]...)
+		groups = append(groups, DuplicateGroup{
+			Size:       group[keep].Size(),
+			Digest:     Digest(fullKeys[group[keep].Abs()]),
+			Keep:       group[keep],
+			Duplicates: duplicates,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Size != groups[j].Size {
+			return groups[i].Size > groups[j].Size
+		}
+		return comparePaths(groups[i].Keep.Abs(), groups[j].Keep.Abs()) < 0
+	})
+	return groups, nil
+}
+
+// splitGroups computes a key for every file of the groups concurrently, and
+// splits each group into subgroups of files sharing the same key. Subgroups
+// with a single file, and files whose key cannot be computed, are dropped.
+// The keys of the remaining files are returned by absolute path.
+func splitGroups(ctx context.Context, groups [][]FileInfo, workers int, key func(FileInfo) (string, error)) ([][]FileInfo, map[string]string, error) {
+	var files []FileInfo
+	for _, group := range groups {
+		files = append(files, group...)
+	}
+
+	keys := make([]string, len(files))
+	errs := make([]error, len(files))
+	forEach(len(files), workers, func(i int) {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			return
+		}
+		keys[i], errs[i] = key(files[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var result [][]FileInfo
+	kept := make(map[string]string)
+	i := 0
+	for _, group := range groups {
+		byKey := make(map[string][]FileInfo)
+		var order []string
+		for range group {
+			if errs[i] == nil {
+				if _, ok := byKey[keys[i]]; !ok {
+					order = append(order, keys[i])
+				}
+				byKey[keys[i]] = append(byKey[keys[i]], files[i])
+			}
+			i++
+		}
+		for _, k := range order {
+			if len(byKey[k]) > 1 {
+				result = append(result, byKey[k])
+				for _, f := range byKey[k] {
+					kept[f.Abs()] = k
+				}
+			}
+		}
+	}
+	return result, kept, nil
+}
+
+// splitByContent splits each group into subgroups of files with the same
+// content, comparing them byte by byte. Subgroups with a single file are dropped.
+func splitByContent(ctx context.Context, groups [][]FileInfo) ([][]FileInfo, error) {
+	var result [][]FileInfo
+	for _, group := range groups {
+		var subgroups [][]FileInfo
+	files:
+		for _, f := range group {
+			for i, sub := range subgroups {
+				same, err := sameContent(ctx, sub[0].Abs(), f.Abs())
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					continue files
+				}
+				if same {
+					subgroups[i] = append(sub, f)
+					continue files
+				}
+			}
+			subgroups = append(subgroups, []FileInfo{f})
+		}
+		for _, sub := range subgroups {
+			if len(sub) > 1 {
+				result = append(result, sub)
+			}
+		}
+	}
+	return result, nil
+}
+
+// partialDigest returns a hash of the first and last n bytes of the file at
+// path, whose size is given. Files of up to 2n bytes are hashed entirely.
+func partialDigest(path string, size, n int64) (Digest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := newXXH64()
+	if size <= 2*n {
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err
+		}
+		return h.Sum(nil), nil
+	}
+
+	buf := make([]byte, n)
+	for _, offset := range []int64{0, size - n} {
+		if _, err := f.ReadAt(buf, offset); err != nil {
+			return nil, err
+		}
+		_, _ = h.Write(buf)
+	}
+	return h.Sum(nil), nil
+}
+
+// sameContent reports whether the files at paths a and b have the same content.
+func sameContent(ctx context.Context, a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA != endB {
+			return false, ErrFileChanged
+		}
+		if endA {
+			return true, nil
+		}
+	}
+}
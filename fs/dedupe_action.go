@@ -0,0 +1,528 @@
+package fs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file provides the consolidation of duplicate files. Consolidation is
+// done in two phases: a plan is first computed, verifying that the files are
+// identical without modifying anything, so that it can be reviewed as a dry
+// run; it is then applied step by step, each step being recorded in a journal
+// that allows reverting the whole operation.
+
+// DedupeAction identifies how a duplicate file is consolidated with the file
+// that is kept.
+type DedupeAction int
+
+const (
+	DedupeHardLink   DedupeAction = iota + 1 // replace the duplicate with a hard link to the kept file
+	DedupeReflink                            // replace the duplicate with a copy-on-write clone of the kept file
+	DedupeSymlink                            // replace the duplicate with a symbolic link to the kept file
+	DedupeQuarantine                         // move the duplicate to a quarantine directory
+)
+
+var (
+	// ErrNotIdentical is returned when planning to consolidate files whose
+	// contents differ.
+	ErrNotIdentical = errors.New("files are not identical")
+
+	// ErrFileChanged is returned when a file changed after it was compared or
+	// planned for consolidation.
+	ErrFileChanged = errors.New("file changed")
+
+	// ErrReflinkUnsupported is returned when the platform or the file system
+	// does not support reflinks.
+	ErrReflinkUnsupported = errors.New("reflinks are not supported")
+)
+
+// String returns the lower-case name of the action, e.g. "hardlink".
+func (a DedupeAction) String() string {
+	switch a {
+	case DedupeHardLink:
+		return "hardlink"
+	case DedupeReflink:
+		return "reflink"
+	case DedupeSymlink:
+		return "symlink"
+	case DedupeQuarantine:
+		return "quarantine"
+	default:
+		return fmt.Sprintf("DedupeAction(%d)", int(a))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the action by name.
+func (a DedupeAction) MarshalText() ([]byte, error) {
+	if a < DedupeHardLink || a > DedupeQuarantine {
+		return nil, fmt.Errorf("invalid dedupe action %d", int(a))
+	}
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *DedupeAction) UnmarshalText(text []byte) error {
+	for action := DedupeHardLink; action <= DedupeQuarantine; action++ {
+		if action.String() == string(text) {
+			*a = action
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid dedupe action %q", text)
+}
+
+// DedupeOptions controls the behavior of PlanDedupe.
+type DedupeOptions struct {
+	// Action is the consolidation applied to duplicates.
+	Action DedupeAction
+
+	// QuarantineDir is the directory duplicates are moved to by
+	// DedupeQuarantine. The absolute path of each duplicate is reproduced
+	// below it, so that duplicates with the same name do not conflict.
+	QuarantineDir string
+}
+
+// DedupeStep is the consolidation of a single duplicate file. Steps are
+// recorded as JSON lines in the journal written by DedupePlan.Apply.
+type DedupeStep struct {
+	Action DedupeAction `json:"action"`
+	Keep   string       `json:"keep"`           // absolute path of the kept file
+	Path   string       `json:"path"`           // absolute path of the duplicate
+	Dest   string       `json:"dest,omitempty"` // quarantine path of the duplicate
+
+	Size        int64       `json:"size"`       // size of both files
+	Mode        os.FileMode `json:"mode"`       // mode of the duplicate
+	ModTime     time.Time   `json:"mtime"`      // modification time of the duplicate
+	KeepModTime time.Time   `json:"keep_mtime"` // modification time of the kept file
+}
+
+// String returns a human-readable description of the step.
+func (s DedupeStep) String() string {
+	if s.Action == DedupeQuarantine {
+		return fmt.Sprintf("%s %s -> %s", s.Action, s.Path, s.Dest)
+	}
+	return fmt.Sprintf("%s %s -> %s", s.Action, s.Path, s.Keep)
+}
+
+// DedupePlan is the list of steps consolidating duplicate files. It can be
+// reviewed before being applied.
+type DedupePlan struct {
+	Steps   []DedupeStep
+	Skipped []string // duplicates already sharing their content with the kept file
+}
+
+// PlanDedupe plans the consolidation of the files at paths with the file at
+// keep, without modifying anything. It verifies that every file has the same
+// size and content as the kept file, and returns an error wrapping
+// ErrNotIdentical otherwise. Files that are hard links to the kept file, or
+// symbolic links to it, are skipped since they do not use additional space,
+// except for DedupeQuarantine which moves hard links too.
+func PlanDedupe(ctx context.Context, keep string, paths []string, opts DedupeOptions) (*DedupePlan, error) {
+	if opts.Action < DedupeHardLink || opts.Action > DedupeQuarantine {
+		return nil, fmt.Errorf("invalid dedupe action %d", int(opts.Action))
+	}
+	quarantineDir := opts.QuarantineDir
+	if opts.Action == DedupeQuarantine {
+		if quarantineDir == "" {
+			return nil, errors.New("quarantine directory not set")
+		}
+		var err error
+		if quarantineDir, err = absolute(quarantineDir); err != nil {
+			return nil, err
+		}
+	}
+
+	keepInfo, err := NewFileInfoLstat(keep)
+	if err != nil {
+		return nil, err
+	}
+	if !keepInfo.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: not a regular file", keepInfo.Abs())
+	}
+
+	plan := &DedupePlan{}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		info, err := NewFileInfoLstat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.Abs() == keepInfo.Abs() {
+			continue
+		}
+		if info.IsSymlink() {
+			if info.Target() != nil && SameFile(info.Target(), keepInfo) {
+				plan.Skipped = append(plan.Skipped, info.Abs())
+				continue
+			}
+			return nil, fmt.Errorf("%s: not a regular file", info.Abs())
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s: not a regular file", info.Abs())
+		}
+		if SameFile(info, keepInfo) && opts.Action != DedupeQuarantine {
+			plan.Skipped = append(plan.Skipped, info.Abs())
+			continue
+		}
+		if opts.Action == DedupeHardLink && info.FileID().Dev != keepInfo.FileID().Dev {
+			return nil, fmt.Errorf("%s: cannot hard link across devices", info.Abs())
+		}
+
+		if info.Size() != keepInfo.Size() {
+			return nil, fmt.Errorf("%s: %w", info.Abs(), ErrNotIdentical)
+		}
+		same, err := sameContent(ctx, keepInfo.Abs(), info.Abs())
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			return nil, fmt.Errorf("%s: %w", info.Abs(), ErrNotIdentical)
+		}
+
+		step := DedupeStep{
+			Action:      opts.Action,
+			Keep:        keepInfo.Abs(),
+			Path:        info.Abs(),
+			Size:        info.Size(),
+			Mode:        info.Mode(),
+			ModTime:     info.LastWriteTime(),
+			KeepModTime: keepInfo.LastWriteTime(),
+		}
+		if opts.Action == DedupeQuarantine {
+			step.Dest = filepath.Join(quarantineDir, strings.TrimPrefix(info.Abs(), filepath.VolumeName(info.Abs())))
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// PlanDedupeGroups plans the consolidation of the duplicates of each group
+// with the file kept in the group, as PlanDedupe does.
+func PlanDedupeGroups(ctx context.Context, groups []DuplicateGroup, opts DedupeOptions) (*DedupePlan, error) {
+	plan := &DedupePlan{}
+	for _, group := range groups {
+		paths := make([]string, len(group.Duplicates))
+		for i, d := range group.Duplicates {
+			paths[i] = d.Abs()
+		}
+		p, err := PlanDedupe(ctx, group.Keep.Abs(), paths, opts)
+		if err != nil {
+			return nil, err
+		}
+		plan.Steps = append(plan.Steps, p.Steps...)
+		plan.Skipped = append(plan.Skipped, p.Skipped...)
+	}
+	return plan, nil
+}
+
+// Apply performs the steps of the plan in order, and records each step in
+// the journal file at journalPath, which is created if needed, before
+// performing it. The journal can then be passed to RollbackDedupe to revert
+// the operation, including after a crash. An empty journalPath disables the
+// journal.
+//
+// Before each step, Apply checks that neither the duplicate nor the kept file
+// changed since the plan was computed, and fails with an error wrapping
+// ErrFileChanged otherwise. Duplicates are replaced atomically, through a
+// temporary file renamed over them. Apply stops at the first error; the steps
+// attempted so far remain recorded in the journal, and RollbackDedupe ignores
+// those that were not performed.
+func (p *DedupePlan) Apply(ctx context.Context, journalPath string) error {
+	var journal *os.File
+	if journalPath != "" {
+		var err error
+		journal, err = os.OpenFile(journalPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		defer journal.Close()
+	}
+
+	for _, step := range p.Steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := step.check(); err != nil {
+			return err
+		}
+		if journal != nil {
+			line, err := json.Marshal(step)
+			if err != nil {
+				return err
+			}
+			if _, err := journal.Write(append(line, '\n')); err != nil {
+				return err
+			}
+			if err := journal.Sync(); err != nil {
+				return err
+			}
+		}
+		if err := step.apply(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check verifies that neither the duplicate nor the kept file changed since
+// the step was planned.
+func (s DedupeStep) check() error {
+	if err := checkUnchanged(s.Keep, s.Size, s.KeepModTime); err != nil {
+		return err
+	}
+	return checkUnchanged(s.Path, s.Size, s.ModTime)
+}
+
+// apply performs the step.
+func (s DedupeStep) apply() error {
+	if err := s.check(); err != nil {
+		return err
+	}
+
+	switch s.Action {
+	case DedupeHardLink:
+		return replaceFile(s.Path, func(tmp string) error {
+			return os.Link(s.Keep, tmp)
+		})
+	case DedupeReflink:
+		return replaceFile(s.Path, func(tmp string) error {
+			if err := reflink(s.Keep, tmp); err != nil {
+				return err
+			}
+			if err := os.Chmod(tmp, s.Mode.Perm()); err != nil {
+				return err
+			}
+			return os.Chtimes(tmp, time.Time{}, s.ModTime)
+		})
+	case DedupeSymlink:
+		return replaceFile(s.Path, func(tmp string) error {
+			return os.Symlink(s.Keep, tmp)
+		})
+	case DedupeQuarantine:
+		return moveFile(s.Path, s.Dest)
+	default:
+		return fmt.Errorf("invalid dedupe action %d", int(s.Action))
+	}
+}
+
+// RollbackDedupe reverts the steps recorded in the journal file at
+// journalPath, in reverse order. Files replaced by links or clones are
+// restored as independent copies with their original mode and modification
+// time, and quarantined files are moved back. A file that changed since the
+// step was applied is left untouched and reported with an error wrapping
+// ErrFileChanged. All the steps are attempted, and the errors are joined;
+// the journal is removed if every step was reverted.
+func RollbackDedupe(ctx context.Context, journalPath string) error {
+	steps, err := readDedupeJournal(journalPath)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, step := range slices.Backward(steps) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := step.rollback(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return os.Remove(journalPath)
+}
+
+// rollback reverts the step. A step recorded in the journal but not
+// performed, because Apply failed or was interrupted, is left as is.
+func (s DedupeStep) rollback() error {
+	if s.Action == DedupeQuarantine {
+		if _, err := os.Lstat(s.Dest); errors.Is(err, os.ErrNotExist) && checkUnchanged(s.Path, s.Size, s.ModTime) == nil {
+			return nil // not performed
+		}
+		if _, err := os.Lstat(s.Path); err == nil {
+			return fmt.Errorf("%s: %w", s.Path, os.ErrExist)
+		}
+		if err := checkUnchanged(s.Dest, s.Size, s.ModTime); err != nil {
+			return err
+		}
+		return moveFile(s.Dest, s.Path)
+	}
+
+	info, err := os.Lstat(s.Path)
+	if err != nil {
+		return err
+	}
+	source := s.Path
+	switch s.Action {
+	case DedupeHardLink:
+		keep, err := os.Stat(s.Keep)
+		if err != nil || !os.SameFile(info, keep) {
+			if checkUnchanged(s.Path, s.Size, s.ModTime) == nil {
+				return nil // not performed
+			}
+			return fmt.Errorf("%s: %w", s.Path, ErrFileChanged)
+		}
+	case DedupeReflink:
+		if !info.Mode().IsRegular() || info.Size() != s.Size {
+			return fmt.Errorf("%s: %w", s.Path, ErrFileChanged)
+		}
+	case DedupeSymlink:
+		if target, err := os.Readlink(s.Path); err != nil || target != s.Keep {
+			if checkUnchanged(s.Path, s.Size, s.ModTime) == nil {
+				return nil // not performed
+			}
+			return fmt.Errorf("%s: %w", s.Path, ErrFileChanged)
+		}
+		// The duplicate is restored from the kept file, which must still
+		// hold the content it had.
+		if err := checkUnchanged(s.Keep, s.Size, s.KeepModTime); err != nil {
+			return err
+		}
+		source = s.Keep
+	default:
+		return fmt.Errorf("invalid dedupe action %d", int(s.Action))
+	}
+
+	return replaceFile(s.Path, func(tmp string) error {
+		return copyContent(source, tmp, s.Mode.Perm(), s.ModTime)
+	})
+}
+
+// readDedupeJournal reads the steps recorded in the journal at path.
+func readDedupeJournal(path string) ([]DedupeStep, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var steps []DedupeStep
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var step DedupeStep
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil {
+			return nil, fmt.Errorf("%s: invalid journal entry: %w", path, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, scanner.Err()
+}
+
+// checkUnchanged verifies that the file at path is a regular file with the
+// given size and modification time.
+func checkUnchanged(path string, size int64, modTime time.Time) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() != size || !info.ModTime().Equal(modTime) {
+		return fmt.Errorf("%s: %w", path, ErrFileChanged)
+	}
+	return nil
+}
+
+// replaceFile atomically replaces the file at path with the file created by
+// create at the temporary path it is given, in the same directory. The
+// temporary path is unique, and create must fail with an error wrapping
+// os.ErrExist if a file exists there, so that a file it did not create is
+// never removed.
+func replaceFile(path string, create func(tmp string) error) error {
+	dir, base := filepath.Split(path)
+	for range 100 {
+		tmp := filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 36)+".dedupe~")
+		err := create(tmp)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: no unused temporary name", path)
+}
+
+// moveFile moves the file at src to dst, creating the parent directories of
+// dst. It fails if dst exists. When the file cannot be renamed, typically
+// because dst is on another device, it is copied and then removed.
+func moveFile(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s: %w", dst, os.ErrExist)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := copyContent(src, dst, info.Mode().Perm(), info.ModTime()); err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			_ = os.Remove(dst)
+		}
+		return err
+	}
+	// The copy is removed if the file cannot be, so that the move either
+	// happens or leaves no trace.
+	if err := os.Remove(src); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// copyContent copies the content of the file at src to a new file at dst with
+// the given permissions and modification time. It fails if dst exists.
+func copyContent(src, dst string, perm os.FileMode, modTime time.Time) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, perm); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, time.Time{}, modTime)
+}
@@ -0,0 +1,298 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dedupeTree creates a tree with a kept file and two duplicates, and returns
+// the root, the path of the kept file and the paths of the duplicates.
+func dedupeTree(t *testing.T) (string, string, []string) {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	createTree(t, root, map[string]string{
+		"library/a.jpg":      "photo-a",
+		"import/a.jpg":       "photo-a",
+		"import/deep/a.jpg":  "photo-a",
+		"import/a-edit.jpg":  "photo-A",
+		"import/a-large.jpg": "photo-a-large",
+	})
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "import", "a.jpg"), old, old))
+	require.NoError(t, os.Chmod(filepath.Join(root, "import", "a.jpg"), 0600))
+	return root, filepath.Join(root, "library", "a.jpg"), []string{
+		filepath.Join(root, "import", "a.jpg"),
+		filepath.Join(root, "import", "deep", "a.jpg"),
+	}
+}
+
+// assertIndependent asserts that the file at path is a regular file with the
+// given content that is not shared with the file at other.
+func assertIndependent(t *testing.T, path, other, content string) {
+	t.Helper()
+	info, err := NewFileInfoLstat(path)
+	require.NoError(t, err)
+	otherInfo, err := NewFileInfoLstat(other)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	assert.False(t, SameFile(info, otherInfo))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestPlanDedupe(t *testing.T) {
+	root, keep, dups := dedupeTree(t)
+	ctx := context.Background()
+
+	t.Run("plan", func(t *testing.T) {
+		plan, err := PlanDedupe(ctx, keep, append(dups, keep), DedupeOptions{Action: DedupeHardLink})
+		require.NoError(t, err)
+		require.Len(t, plan.Steps, 2)
+		assert.Empty(t, plan.Skipped)
+		assert.Equal(t, DedupeHardLink, plan.Steps[0].Action)
+		assert.Equal(t, keep, plan.Steps[0].Keep)
+		assert.Equal(t, dups[0], plan.Steps[0].Path)
+		assert.Equal(t, int64(7), plan.Steps[0].Size)
+		assert.Equal(t, "hardlink "+dups[0]+" -> "+keep, plan.Steps[0].String())
+	})
+
+	t.Run("not identical", func(t *testing.T) {
+		for _, name := range []string{"a-edit.jpg", "a-large.jpg"} {
+			_, err := PlanDedupe(ctx, keep, []string{filepath.Join(root, "import", name)}, DedupeOptions{Action: DedupeSymlink})
+			assert.ErrorIs(t, err, ErrNotIdentical)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := PlanDedupe(ctx, keep, dups, DedupeOptions{})
+		assert.Error(t, err)
+		_, err = PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeQuarantine})
+		assert.Error(t, err)
+	})
+
+	t.Run("quarantine destination", func(t *testing.T) {
+		quarantine := filepath.Join(root, "quarantine")
+		plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeQuarantine, QuarantineDir: quarantine})
+		require.NoError(t, err)
+		require.Len(t, plan.Steps, 2)
+		dest := filepath.Join(quarantine, dups[0][len(filepath.VolumeName(dups[0])):])
+		assert.Equal(t, dest, plan.Steps[0].Dest)
+	})
+
+	t.Run("already linked", func(t *testing.T) {
+		link := filepath.Join(root, "library", "a-link.jpg")
+		if err := os.Link(keep, link); err != nil {
+			t.Skip("Skipping hard link test because hard link could not be created")
+		}
+		defer os.Remove(link)
+
+		plan, err := PlanDedupe(ctx, keep, []string{link}, DedupeOptions{Action: DedupeReflink})
+		require.NoError(t, err)
+		assert.Empty(t, plan.Steps)
+		assert.Equal(t, []string{link}, plan.Skipped)
+
+		plan, err = PlanDedupe(ctx, keep, []string{link}, DedupeOptions{Action: DedupeQuarantine, QuarantineDir: root})
+		require.NoError(t, err)
+		assert.Len(t, plan.Steps, 1)
+	})
+}
+
+func TestApplyDedupe(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hardlink", func(t *testing.T) {
+		root, keep, dups := dedupeTree(t)
+		plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeHardLink})
+		require.NoError(t, err)
+
+		journal := filepath.Join(root, "journal.jsonl")
+		if err := plan.Apply(ctx, journal); err != nil {
+			t.Skipf("Skipping hard link test because hard link could not be created: %v", err)
+		}
+		keepInfo, err := NewFileInfo(keep)
+		require.NoError(t, err)
+		for _, dup := range dups {
+			info, err := NewFileInfo(dup)
+			require.NoError(t, err)
+			assert.True(t, SameFile(keepInfo, info))
+		}
+
+		require.NoError(t, RollbackDedupe(ctx, journal))
+		for _, dup := range dups {
+			assertIndependent(t, dup, keep, "photo-a")
+		}
+		info, err := os.Stat(dups[0])
+		require.NoError(t, err)
+		assert.Equal(t, plan.Steps[0].ModTime, info.ModTime())
+		assert.NoFileExists(t, journal)
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		root, keep, dups := dedupeTree(t)
+		plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeSymlink})
+		require.NoError(t, err)
+
+		journal := filepath.Join(root, "journal.jsonl")
+		if err := plan.Apply(ctx, journal); err != nil {
+			t.Skipf("Skipping symlink test because symlink could not be created: %v", err)
+		}
+		for _, dup := range dups {
+			info, err := NewFileInfoLstat(dup)
+			require.NoError(t, err)
+			assert.True(t, info.IsSymlink())
+			assert.Equal(t, keep, info.LinkTarget())
+		}
+
+		require.NoError(t, RollbackDedupe(ctx, journal))
+		for _, dup := range dups {
+			assertIndependent(t, dup, keep, "photo-a")
+		}
+	})
+
+	t.Run("symlink with kept file changed", func(t *testing.T) {
+		root, keep, dups := dedupeTree(t)
+		plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeSymlink})
+		require.NoError(t, err)
+
+		journal := filepath.Join(root, "journal.jsonl")
+		if err := plan.Apply(ctx, journal); err != nil {
+			t.Skipf("Skipping symlink test because symlink could not be created: %v", err)
+		}
+		require.NoError(t, os.WriteFile(keep, []byte("edited"), 0644))
+
+		err = RollbackDedupe(ctx, journal)
+		assert.ErrorIs(t, err, ErrFileChanged)
+		for _, dup := range dups {
+			info, err := NewFileInfoLstat(dup)
+			require.NoError(t, err)
+			assert.True(t, info.IsSymlink())
+		}
+		assert.FileExists(t, journal)
+	})
+
+	t.Run("reflink", func(t *testing.T) {
+		root, keep, dups := dedupeTree(t)
+		plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeReflink})
+		require.NoError(t, err)
+
+		journal := filepath.Join(root, "journal.jsonl")
+		err = plan.Apply(ctx, journal)
+		if errors.Is(err, ErrReflinkUnsupported) {
+			assertIndependent(t, dups[0], keep, "photo-a")
+			t.Skip("Skipping reflink test because the file system does not support reflinks")
+		}
+		require.NoError(t, err)
+		for _, dup := range dups {
+			assertIndependent(t, dup, keep, "photo-a")
+		}
+		require.NoError(t, RollbackDedupe(ctx, journal))
+	})
+
+	t.Run("quarantine", func(t *testing.T) {
+		root, keep, dups := dedupeTree(t)
+		quarantine := filepath.Join(root, "quarantine")
+		plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeQuarantine, QuarantineDir: quarantine})
+		require.NoError(t, err)
+
+		journal := filepath.Join(root, "journal.jsonl")
+		require.NoError(t, plan.Apply(ctx, journal))
+		for _, step := range plan.Steps {
+			assert.NoFileExists(t, step.Path)
+			assert.FileExists(t, step.Dest)
+		}
+
+		require.NoError(t, RollbackDedupe(ctx, journal))
+		for _, step := range plan.Steps {
+			assert.FileExists(t, step.Path)
+			assert.NoFileExists(t, step.Dest)
+		}
+	})
+
+	t.Run("changed after planning", func(t *testing.T) {
+		root, keep, dups := dedupeTree(t)
+		plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeHardLink})
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(dups[0], []byte("photo-b"), 0644))
+		err = plan.Apply(ctx, filepath.Join(root, "journal.jsonl"))
+		assert.ErrorIs(t, err, ErrFileChanged)
+
+		data, err := os.ReadFile(dups[0])
+		require.NoError(t, err)
+		assert.Equal(t, "photo-b", string(data))
+	})
+
+	t.Run("journaled but not performed", func(t *testing.T) {
+		root, keep, dups := dedupeTree(t)
+		journal := filepath.Join(root, "journal.jsonl")
+		var lines []byte
+		for _, action := range []DedupeAction{DedupeHardLink, DedupeSymlink, DedupeQuarantine} {
+			plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: action, QuarantineDir: filepath.Join(root, "quarantine")})
+			require.NoError(t, err)
+			for _, step := range plan.Steps {
+				line, err := json.Marshal(step)
+				require.NoError(t, err)
+				lines = append(append(lines, line...), '\n')
+			}
+		}
+		require.NoError(t, os.WriteFile(journal, lines, 0644))
+
+		require.NoError(t, RollbackDedupe(ctx, journal))
+		for _, dup := range dups {
+			assertIndependent(t, dup, keep, "photo-a")
+		}
+		assert.NoFileExists(t, journal)
+	})
+
+	t.Run("unrelated temporary file", func(t *testing.T) {
+		root, keep, dups := dedupeTree(t)
+		other := filepath.Join(root, "import", ".a.jpg.dedupe~")
+		require.NoError(t, os.WriteFile(other, []byte("user data"), 0644))
+		plan, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeSymlink})
+		require.NoError(t, err)
+
+		if err := plan.Apply(ctx, ""); err != nil {
+			t.Skipf("Skipping symlink test because symlink could not be created: %v", err)
+		}
+		data, err := os.ReadFile(other)
+		require.NoError(t, err)
+		assert.Equal(t, "user data", string(data))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		_, keep, dups := dedupeTree(t)
+		_, err := PlanDedupe(ctx, keep, dups, DedupeOptions{Action: DedupeHardLink})
+		require.NoError(t, err)
+		for _, dup := range dups {
+			assertIndependent(t, dup, keep, "photo-a")
+		}
+	})
+}
+
+func TestMoveFile(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Skipping permission test because it runs as root")
+	}
+	root := t.TempDir()
+	createTree(t, root, map[string]string{"locked/a.jpg": "a"})
+	src := filepath.Join(root, "locked", "a.jpg")
+	dst := filepath.Join(root, "quarantine", "a.jpg")
+
+	// The file can be neither renamed nor removed, but can be copied.
+	require.NoError(t, os.Chmod(filepath.Dir(src), 0555))
+	defer os.Chmod(filepath.Dir(src), 0755)
+
+	assert.Error(t, moveFile(src, dst))
+	assert.FileExists(t, src)
+	assert.NoFileExists(t, dst)
+}
@@ -0,0 +1,140 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// duplicatePaths returns the paths of the files kept and duplicated in each
+// group, relative to root and slash-separated, the kept file first.
+func duplicatePaths(t *testing.T, root string, groups []DuplicateGroup) [][]string {
+	t.Helper()
+	rel := func(info FileInfo) string {
+		r, err := filepath.Rel(root, info.Abs())
+		require.NoError(t, err)
+		return filepath.ToSlash(r)
+	}
+	result := make([][]string, len(groups))
+	for i, group := range groups {
+		result[i] = []string{rel(group.Keep)}
+		for _, d := range group.Duplicates {
+			result[i] = append(result[i], rel(d))
+		}
+	}
+	return result
+}
+
+func TestFindDuplicates(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	big := strings.Repeat("x", 10000)
+	createTree(t, root, map[string]string{
+		"import/a.jpg":           "photo-a",
+		"import/deep/a copy.jpg": "photo-a",
+		"library/a.jpg":          "photo-a",
+		"import/b.jpg":           "photo-b",
+		"import/c.jpg":           "photo-c",
+		"import/empty1.txt":      "",
+		"import/empty2.txt":      "",
+		"library/big.mov":        big,
+		"import/big.mov":         big,
+		"import/big-edit.mov":    big[:5000] + "y" + big[5001:],
+	})
+
+	t.Run("groups", func(t *testing.T) {
+		groups, err := FindDuplicates(context.Background(), root, DuplicateOptions{Keeper: KeepShortestPath})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"import/big.mov", "library/big.mov"},
+			{"import/a.jpg", "import/deep/a copy.jpg", "library/a.jpg"},
+		}, duplicatePaths(t, root, groups))
+
+		require.Len(t, groups, 2)
+		assert.Equal(t, int64(10000), groups[0].Size)
+		digest, err := HashFile(context.Background(), filepath.Join(root, "import", "big.mov"), HashSHA256)
+		require.NoError(t, err)
+		assert.Equal(t, digest, groups[0].Digest)
+	})
+
+	t.Run("byte compare and algorithm", func(t *testing.T) {
+		groups, err := FindDuplicates(context.Background(), root, DuplicateOptions{
+			Algorithm:   HashXXH64,
+			ByteCompare: true,
+			PartialSize: 16,
+			Keeper:      KeepShortestPath,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"import/big.mov", "library/big.mov"},
+			{"import/a.jpg", "import/deep/a copy.jpg", "library/a.jpg"},
+		}, duplicatePaths(t, root, groups))
+		require.Len(t, groups, 2)
+		assert.Len(t, groups[0].Digest, 8)
+	})
+
+	t.Run("min size", func(t *testing.T) {
+		groups, err := FindDuplicates(context.Background(), root, DuplicateOptions{MinSize: 100})
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		assert.Equal(t, int64(10000), groups[0].Size)
+	})
+
+	t.Run("keep in dir", func(t *testing.T) {
+		groups, err := FindDuplicates(context.Background(), root, DuplicateOptions{
+			Keeper: KeepInDir(KeepShortestPath, filepath.Join(root, "import", "deep"), filepath.Join(root, "library")),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"library/big.mov", "import/big.mov"},
+			{"import/deep/a copy.jpg", "import/a.jpg", "library/a.jpg"},
+		}, duplicatePaths(t, root, groups))
+	})
+
+	t.Run("hard links", func(t *testing.T) {
+		linkRoot, err := filepath.EvalSymlinks(t.TempDir())
+		require.NoError(t, err)
+		createTree(t, linkRoot, map[string]string{"a.jpg": "photo-a", "b.jpg": "photo-a"})
+		if err := os.Link(filepath.Join(linkRoot, "a.jpg"), filepath.Join(linkRoot, "c.jpg")); err != nil {
+			t.Skip("Skipping hard link test because hard link could not be created")
+		}
+
+		groups, err := FindDuplicates(context.Background(), linkRoot, DuplicateOptions{Keeper: KeepShortestPath})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"a.jpg", "b.jpg"}}, duplicatePaths(t, linkRoot, groups))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := FindDuplicates(ctx, root, DuplicateOptions{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestKeeperPolicies(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	createTree(t, root, map[string]string{
+		"import/a.jpg":         "a",
+		"library/nested/a.jpg": "a",
+	})
+
+	files := make([]FileInfo, 0, 2)
+	for _, name := range []string{"import/a.jpg", "library/nested/a.jpg"} {
+		info, err := NewFileInfo(filepath.Join(root, name))
+		require.NoError(t, err)
+		files = append(files, info)
+	}
+
+	assert.Equal(t, 0, KeepShortestPath(files))
+	assert.Equal(t, 1, KeepInDir(nil, filepath.Join(root, "library"))(files))
+	assert.Equal(t, 0, KeepInDir(KeepShortestPath, filepath.Join(root, "missing"))(files))
+	assert.Contains(t, []int{0, 1}, KeepOldest(files))
+}
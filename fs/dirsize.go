@@ -0,0 +1,252 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file provides a cross-platform directory size calculator. Unlike
+// GetSize, which reports the size of the directory entry itself, it sums the
+// sizes of the files within the directory, recursively, and reports both the
+// apparent size and the size allocated on disk.
+
+// DiskUsage describes the space used by the files within a directory.
+type DiskUsage struct {
+	Apparent  int64 // sum of the file sizes, in bytes
+	Allocated int64 // space allocated on disk for the files, in bytes
+	Files     int64 // number of files
+	Dirs      int64 // number of directories, including the root
+}
+
+// DirSizeOptions controls the behavior of DirSize.
+type DirSizeOptions struct {
+	// Workers is the number of concurrent workers reading directories.
+	// A value of 0 or less uses runtime.NumCPU().
+	Workers int
+
+	// Cache, if not nil, is used to reuse recent results for the same
+	// directory and stores new results.
+	Cache *SizeCache
+}
+
+// DirSize computes the disk usage of the directory at path, recursively,
+// using a bounded pool of workers. Hidden files are included, symbolic links
+// below path are not followed, and files reachable through several hard links
+// are counted once. If path is itself a symbolic link, its target is measured.
+// If path is a file, its own usage is returned.
+//
+// Errors reading a directory or describing a file do not abort the
+// computation: the usage of the accessible files is returned together with
+// the joined errors. If ctx is cancelled, the context error is returned.
+func DirSize(ctx context.Context, path string, opts DirSizeOptions) (DiskUsage, error) {
+	absPath, err := absolute(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+
+	if opts.Cache != nil {
+		if usage, ok := opts.Cache.get(absPath); ok {
+			return usage, nil
+		}
+	}
+
+	// The root is resolved, so that a link to a directory is walked rather
+	// than measured itself.
+	root, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	info, err := os.Lstat(root)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+
+	d := dirSizer{seen: make(map[FileID]bool)}
+	if !info.IsDir() {
+		d.addFile(info, root)
+		return d.usage, nil
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	d.queue.cond = sync.NewCond(&d.queue.mu)
+	d.queue.push(scanJob{path: root, info: info})
+
+	stop := context.AfterFunc(ctx, d.queue.cancel)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return DiskUsage{}, err
+	}
+	if len(d.errs) > 0 {
+		return d.usage, errors.Join(d.errs...)
+	}
+
+	if opts.Cache != nil {
+		opts.Cache.put(absPath, d.usage)
+	}
+	return d.usage, nil
+}
+
+// dirSizer holds the state of a single DirSize call. It reuses the job queue
+// of the scanner, with one job per directory.
+type dirSizer struct {
+	queue scanQueue
+
+	mu    sync.Mutex
+	usage DiskUsage
+	seen  map[FileID]bool // files already counted
+	errs  []error
+}
+
+// work processes directories until the queue is drained or cancelled.
+func (d *dirSizer) work(ctx context.Context) {
+	for {
+		job, ok := d.queue.pop()
+		if !ok {
+			return
+		}
+		if ctx.Err() == nil {
+			d.process(job.path)
+		}
+		d.queue.done()
+	}
+}
+
+// process adds the usage of the files in the directory at path and queues
+// its subdirectories.
+func (d *dirSizer) process(path string) {
+	d.mu.Lock()
+	d.usage.Dirs++
+	d.mu.Unlock()
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		d.addError(path, err)
+		return
+	}
+
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+		if entry.IsDir() {
+			d.queue.push(scanJob{path: childPath})
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			d.addError(childPath, err)
+			continue
+		}
+		d.addFile(info, childPath)
+	}
+}
+
+// addFile adds the usage of a file, unless it was already counted through
+// another hard link.
+func (d *dirSizer) addFile(info os.FileInfo, path string) {
+	allocated := getAllocatedSize(info, path)
+	sys := newSysInfo(info, path)
+	id := FileID{Dev: sys.Dev, Ino: sys.Ino}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if sys.Nlink > 1 && !id.IsZero() {
+		if d.seen[id] {
+			return
+		}
+		d.seen[id] = true
+	}
+	d.usage.Files++
+	d.usage.Apparent += info.Size()
+	d.usage.Allocated += allocated
+}
+
+// addError records an error for path.
+func (d *dirSizer) addError(path string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.errs = append(d.errs, &ScanError{Path: path, Err: err})
+}
+
+// SizeCache caches the results of DirSize for a limited time. Since the
+// size of a directory changes whenever a file below it changes, without any
+// change to the directory itself, cached results are only trusted for the
+// configured time to live. It is safe for concurrent use.
+type SizeCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]sizeCacheEntry
+}
+
+// sizeCacheEntry is a cached DirSize result.
+type sizeCacheEntry struct {
+	usage   DiskUsage
+	expires time.Time
+}
+
+// NewSizeCache creates a SizeCache whose entries expire after ttl.
+func NewSizeCache(ttl time.Duration) *SizeCache {
+	return &SizeCache{
+		ttl:     ttl,
+		entries: make(map[string]sizeCacheEntry),
+	}
+}
+
+// Invalidate removes the cached results for path and for the directories
+// containing it, whose sizes include it.
+func (c *SizeCache) Invalidate(path string) {
+	absPath, err := absolute(path)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.entries {
+		if p == absPath || strings.HasPrefix(absPath, strings.TrimSuffix(p, string(filepath.Separator))+string(filepath.Separator)) {
+			delete(c.entries, p)
+		}
+	}
+}
+
+// get returns the cached result for path, if it has not expired.
+func (c *SizeCache) get(path string) (DiskUsage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[path]
+	if !ok {
+		return DiskUsage{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, path)
+		return DiskUsage{}, false
+	}
+	return entry.usage, true
+}
+
+// put stores the result for path.
+func (c *SizeCache) put(path string, usage DiskUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = sizeCacheEntry{usage: usage, expires: time.Now().Add(c.ttl)}
+}
@@ -0,0 +1,124 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirSize(t *testing.T) {
+	root := t.TempDir()
+	createTree(t, root, map[string]string{
+		"a.jpg":           "aaaa",
+		".hidden":         "hh",
+		"album/b.jpg":     "bbbbbbbb",
+		"album/sub/c.mp4": "cccccccccccccccc",
+		"empty/":          "",
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		usage, err := DirSize(context.Background(), root, DirSizeOptions{Workers: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4+2+8+16), usage.Apparent)
+		assert.Equal(t, int64(4), usage.Files)
+		assert.Equal(t, int64(4), usage.Dirs)
+		assert.GreaterOrEqual(t, usage.Allocated, int64(0))
+	})
+
+	t.Run("file", func(t *testing.T) {
+		usage, err := DirSize(context.Background(), filepath.Join(root, "a.jpg"), DirSizeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, DiskUsage{Apparent: 4, Allocated: usage.Allocated, Files: 1}, usage)
+	})
+
+	t.Run("hard links", func(t *testing.T) {
+		dir := t.TempDir()
+		createTree(t, dir, map[string]string{"a.jpg": "aaaa"})
+		if err := os.Link(filepath.Join(dir, "a.jpg"), filepath.Join(dir, "b.jpg")); err != nil {
+			t.Skip("Skipping hard link test because hard link could not be created")
+		}
+		usage, err := DirSize(context.Background(), dir, DirSizeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), usage.Apparent)
+		assert.Equal(t, int64(1), usage.Files)
+	})
+
+	t.Run("symbolic link", func(t *testing.T) {
+		link := filepath.Join(t.TempDir(), "link")
+		if err := os.Symlink(filepath.Join(root, "album"), link); err != nil {
+			t.Skip("Skipping symbolic link test because symbolic link could not be created")
+		}
+		usage, err := DirSize(context.Background(), link, DirSizeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(8+16), usage.Apparent)
+		assert.Equal(t, int64(2), usage.Files)
+		assert.Equal(t, int64(2), usage.Dirs)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := DirSize(ctx, root, DirSizeOptions{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("non-existing path", func(t *testing.T) {
+		_, err := DirSize(context.Background(), filepath.Join(root, "missing"), DirSizeOptions{})
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestSizeCache(t *testing.T) {
+	root := t.TempDir()
+	createTree(t, root, map[string]string{
+		"album/a.jpg": "aaaa",
+	})
+	album := filepath.Join(root, "album")
+	cache := NewSizeCache(time.Hour)
+	opts := DirSizeOptions{Cache: cache}
+
+	usage, err := DirSize(context.Background(), root, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), usage.Apparent)
+
+	// Cached results are returned until invalidated.
+	createTree(t, root, map[string]string{"album/b.jpg": "bb"})
+	usage, err = DirSize(context.Background(), root, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), usage.Apparent)
+
+	cache.Invalidate(filepath.Join(album, "b.jpg"))
+	usage, err = DirSize(context.Background(), root, opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), usage.Apparent)
+
+	// Expired results are recomputed.
+	expired := NewSizeCache(0)
+	_, err = DirSize(context.Background(), album, DirSizeOptions{Cache: expired})
+	require.NoError(t, err)
+	createTree(t, root, map[string]string{"album/c.jpg": "c"})
+	usage, err = DirSize(context.Background(), album, DirSizeOptions{Cache: expired})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), usage.Apparent)
+}
+
+func TestNewFileInfoWithDirSize(t *testing.T) {
+	root := t.TempDir()
+	createTree(t, root, map[string]string{
+		"album/a.jpg":     "aaaa",
+		"album/sub/b.jpg": "bbbbbbbb",
+	})
+
+	info, err := NewFileInfo(filepath.Join(root, "album"), WithDirSize(DirSizeOptions{}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), info.Size())
+
+	info, err = NewFileInfo(filepath.Join(root, "album", "a.jpg"), WithDirSize(DirSizeOptions{}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), info.Size())
+}
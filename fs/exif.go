@@ -0,0 +1,411 @@
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// This file provides a pure Go reader for the EXIF metadata embedded in
+// photos: capture time, camera, lens, exposure, orientation, dimensions and
+// location. It supports JPEG, HEIF, PNG and WebP images, as well as TIFF and
+// the camera RAW formats based on it. Only the headers holding the metadata are
+// read, so that large libraries can be processed quickly.
+
+// ErrNoExif is returned when a file contains no EXIF metadata, or is not in
+// a format supported by the EXIF reader.
+var ErrNoExif = errors.New("no EXIF metadata")
+
+// EXIF tags, in IFD0 and in the EXIF and GPS directories it points to.
+const (
+	exifTagImageWidth   = 0x0100
+	exifTagImageHeight  = 0x0101
+	exifTagMake         = 0x010F
+	exifTagModel        = 0x0110
+	exifTagOrientation  = 0x0112
+	exifTagSoftware     = 0x0131
+	exifTagDateTime     = 0x0132
+	exifTagExifIFD      = 0x8769
+	exifTagGPSIFD       = 0x8825
+	exifTagExposureTime = 0x829A
+	exifTagFNumber      = 0x829D
+	exifTagISO          = 0x8827
+
+	exifTagDateTimeOriginal    = 0x9003
+	exifTagDateTimeDigitized   = 0x9004
+	exifTagOffsetTime          = 0x9010
+	exifTagOffsetTimeOriginal  = 0x9011
+	exifTagOffsetTimeDigitized = 0x9012
+	exifTagSubSecTime          = 0x9290
+	exifTagSubSecTimeOriginal  = 0x9291
+	exifTagSubSecTimeDigitized = 0x9292
+
+	exifTagFocalLength     = 0x920A
+	exifTagPixelXDimension = 0xA002
+	exifTagPixelYDimension = 0xA003
+	exifTagFocalLength35mm = 0xA405
+	exifTagLensMake        = 0xA433
+	exifTagLensModel       = 0xA434
+
+	gpsTagLatitudeRef  = 0x01
+	gpsTagLatitude     = 0x02
+	gpsTagLongitudeRef = 0x03
+	gpsTagLongitude    = 0x04
+	gpsTagAltitudeRef  = 0x05
+	gpsTagAltitude     = 0x06
+	gpsTagTimeStamp    = 0x07
+	gpsTagDateStamp    = 0x1D
+)
+
+// Exif is the EXIF metadata of a photo. Fields that are not recorded in the
+// file are left zero.
+//
+// EXIF times are recorded as wall clock readings. They are returned in the
+// time zone given by the corresponding offset tag when present, and in the
+// local time zone otherwise.
+type Exif struct {
+	Make      string // camera manufacturer
+	Model     string // camera model
+	LensMake  string // lens manufacturer
+	LensModel string // lens model
+	Software  string // firmware or software that wrote the file
+
+	DateTimeOriginal  time.Time // when the photo was taken
+	DateTimeDigitized time.Time // when the photo was digitized
+	DateTime          time.Time // when the file was last changed
+	OffsetTime        string    // UTC offset of DateTimeOriginal, e.g. "+02:00"
+
+	ExposureTime    Rational // exposure time, in seconds
+	FNumber         float64  // aperture f-number
+	ISO             int      // ISO speed
+	FocalLength     float64  // focal length, in millimeters
+	FocalLength35mm int      // focal length equivalent for a 35 mm film camera, in millimeters
+
+	Orientation int // orientation from 1 to 8, as defined by the TIFF specification
+	Width       int // width of the image, in pixels
+	Height      int // height of the image, in pixels
+
+	GPS *GPSInfo // location where the photo was taken, nil if not recorded
+}
+
+// GPSInfo is the location recorded in EXIF metadata.
+type GPSInfo struct {
+	Latitude  float64   // latitude in degrees, negative in the southern hemisphere
+	Longitude float64   // longitude in degrees, negative west of Greenwich
+	Altitude  float64   // altitude in meters, negative below sea level
+	Time      time.Time // UTC time of the GPS fix, zero if not recorded
+}
+
+// ReadExif reads the EXIF metadata of the file at path. It returns an error
+// wrapping ErrNoExif if the file has none or its format is not supported.
+func ReadExif(path string) (*Exif, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	x, err := DecodeExif(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return x, nil
+}
+
+// DecodeExif reads the EXIF metadata of the image read from r, detecting its
+// format from its leading bytes.
+func DecodeExif(r io.ReaderAt) (*Exif, error) {
+	head := make([]byte, sniffLen)
+	n, err := r.ReadAt(head, 0)
+	if n == 0 && err != nil {
+		return nil, ErrNoExif
+	}
+	head = head[:n]
+
+	switch DetectContentType(head).MIME {
+	case ContentTypeJPEG.MIME:
+		return decodeJPEGExif(r)
+	case ContentTypeTIFF.MIME, ContentTypeCR2.MIME, ContentTypeORF.MIME, ContentTypeRW2.MIME:
+		return decodeTIFFExif(r)
+	case ContentTypeHEIC.MIME, ContentTypeHEIF.MIME, ContentTypeAVIF.MIME:
+		return decodeHEIFExif(r)
+	case ContentTypePNG.MIME:
+		return decodePNGExif(r)
+	case ContentTypeWebP.MIME:
+		return decodeWebPExif(r)
+	case ContentTypeRAF.MIME:
+		// Fujifilm RAF files embed a JPEG preview holding the EXIF metadata.
+		if len(head) < 92 {
+			return nil, ErrNoExif
+		}
+		offset := binary.BigEndian.Uint32(head[84:88])
+		length := binary.BigEndian.Uint32(head[88:92])
+		return decodeJPEGExif(io.NewSectionReader(r, int64(offset), int64(length)))
+	default:
+		return nil, ErrNoExif
+	}
+}
+
+// decodeJPEGExif reads the EXIF metadata of the JPEG image read from r,
+// stored in its APP1 segment. The segments are read up to the start of the
+// compressed data. The dimensions of the frame are used when the EXIF
+// metadata does not record them.
+func decodeJPEGExif(r io.ReaderAt) (*Exif, error) {
+	var exif *io.SectionReader
+	var width, height int
+
+	offset := int64(2) // after the SOI marker
+	var header [4]byte
+scan:
+	for {
+		if _, err := r.ReadAt(header[:2], offset); err != nil {
+			break
+		}
+		if header[0] != 0xFF {
+			return nil, errors.New("invalid JPEG segment")
+		}
+		marker := header[1]
+		switch {
+		case marker == 0xFF: // fill byte
+			offset++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8): // markers without length
+			offset += 2
+			continue
+		case marker == 0xDA || marker == 0xD9: // start of scan, end of image
+			break scan
+		}
+
+		if _, err := r.ReadAt(header[2:4], offset+2); err != nil {
+			break
+		}
+		length := int64(binary.BigEndian.Uint16(header[2:4]))
+		if length < 2 {
+			return nil, errors.New("invalid JPEG segment")
+		}
+		data := io.NewSectionReader(r, offset+4, length-2)
+
+		switch {
+		case marker == 0xE1 && exif == nil:
+			var id [6]byte
+			if _, err := data.ReadAt(id[:], 0); err == nil && string(id[:]) == "Exif\x00\x00" {
+				exif = io.NewSectionReader(data, 6, length-8)
+			}
+		case marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			var frame [5]byte
+			if _, err := data.ReadAt(frame[:], 0); err == nil {
+				height = int(binary.BigEndian.Uint16(frame[1:3]))
+				width = int(binary.BigEndian.Uint16(frame[3:5]))
+			}
+		}
+		offset += 2 + length
+	}
+
+	if exif == nil {
+		return nil, ErrNoExif
+	}
+	x, err := decodeTIFFExif(exif)
+	if err != nil {
+		return nil, err
+	}
+	if x.Width == 0 || x.Height == 0 {
+		x.Width, x.Height = width, height
+	}
+	return x, nil
+}
+
+// decodePNGExif reads the EXIF metadata of the PNG image read from r, stored
+// in its eXIf chunk, which must precede the image data.
+func decodePNGExif(r io.ReaderAt) (*Exif, error) {
+	offset := int64(8) // after the signature
+	var header [8]byte
+	for {
+		if _, err := r.ReadAt(header[:], offset); err != nil {
+			return nil, ErrNoExif
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		switch string(header[4:8]) {
+		case "eXIf":
+			return decodeTIFFExif(io.NewSectionReader(r, offset+8, length))
+		case "IDAT", "IEND":
+			return nil, ErrNoExif
+		}
+		offset += 12 + length // length, type, data and CRC
+	}
+}
+
+// decodeWebPExif reads the EXIF metadata of the WebP image read from r,
+// stored in its EXIF chunk.
+func decodeWebPExif(r io.ReaderAt) (*Exif, error) {
+	offset := int64(12) // after the RIFF header
+	var header [8]byte
+	for {
+		if _, err := r.ReadAt(header[:], offset); err != nil {
+			return nil, ErrNoExif
+		}
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		if string(header[:4]) == "EXIF" {
+			data := io.NewSectionReader(r, offset+8, size)
+			// Some encoders keep the JPEG APP1 identifier.
+			var id [6]byte
+			if _, err := data.ReadAt(id[:], 0); err == nil && string(id[:]) == "Exif\x00\x00" {
+				data = io.NewSectionReader(data, 6, size-6)
+			}
+			return decodeTIFFExif(data)
+		}
+		offset += 8 + size + size%2 // chunks are padded to an even size
+	}
+}
+
+// decodeTIFFExif reads the EXIF metadata of the TIFF structure read from r.
+// Errors reading the EXIF and GPS directories are ignored, so that the
+// metadata of IFD0 is returned even if they are corrupted.
+func decodeTIFFExif(r io.ReaderAt) (*Exif, error) {
+	t, offset, err := newTIFFReader(r)
+	if err != nil {
+		return nil, err
+	}
+	ifd0, _, err := t.readIFD(offset)
+	if err != nil {
+		return nil, err
+	}
+	var ifd tiffIFD // the EXIF directory
+	if offset, ok := t.uintOf(ifd0, exifTagExifIFD, 0); ok {
+		ifd, _, _ = t.readIFD(offset)
+	}
+
+	x := &Exif{
+		Make:      t.stringOf(ifd0, exifTagMake),
+		Model:     t.stringOf(ifd0, exifTagModel),
+		Software:  t.stringOf(ifd0, exifTagSoftware),
+		LensMake:  t.stringOf(ifd, exifTagLensMake),
+		LensModel: t.stringOf(ifd, exifTagLensModel),
+	}
+
+	x.OffsetTime = t.stringOf(ifd, exifTagOffsetTimeOriginal)
+	if x.OffsetTime == "" {
+		x.OffsetTime = t.stringOf(ifd, exifTagOffsetTime)
+	}
+	x.DateTimeOriginal = parseExifTime(t.stringOf(ifd, exifTagDateTimeOriginal),
+		t.stringOf(ifd, exifTagSubSecTimeOriginal), x.OffsetTime)
+	x.DateTimeDigitized = parseExifTime(t.stringOf(ifd, exifTagDateTimeDigitized),
+		t.stringOf(ifd, exifTagSubSecTimeDigitized), t.stringOf(ifd, exifTagOffsetTimeDigitized))
+	x.DateTime = parseExifTime(t.stringOf(ifd0, exifTagDateTime),
+		t.stringOf(ifd, exifTagSubSecTime), t.stringOf(ifd, exifTagOffsetTime))
+
+	if v, ok := t.rationalOf(ifd, exifTagExposureTime, 0); ok {
+		x.ExposureTime = v
+	}
+	if v, ok := t.rationalOf(ifd, exifTagFNumber, 0); ok {
+		x.FNumber = v.Float64()
+	}
+	if v, ok := t.uintOf(ifd, exifTagISO, 0); ok {
+		x.ISO = int(v)
+	}
+	if v, ok := t.rationalOf(ifd, exifTagFocalLength, 0); ok {
+		x.FocalLength = v.Float64()
+	}
+	if v, ok := t.uintOf(ifd, exifTagFocalLength35mm, 0); ok {
+		x.FocalLength35mm = int(v)
+	}
+	if v, ok := t.uintOf(ifd0, exifTagOrientation, 0); ok {
+		x.Orientation = int(v)
+	}
+
+	// The dimensions of the EXIF directory describe the main image, whereas
+	// those of IFD0 may describe a thumbnail.
+	width, okWidth := t.uintOf(ifd, exifTagPixelXDimension, 0)
+	height, okHeight := t.uintOf(ifd, exifTagPixelYDimension, 0)
+	if !okWidth || !okHeight || width == 0 || height == 0 {
+		width, _ = t.uintOf(ifd0, exifTagImageWidth, 0)
+		height, _ = t.uintOf(ifd0, exifTagImageHeight, 0)
+	}
+	x.Width, x.Height = int(width), int(height)
+
+	if offset, ok := t.uintOf(ifd0, exifTagGPSIFD, 0); ok {
+		if gps, _, err := t.readIFD(offset); err == nil {
+			x.GPS = decodeGPS(t, gps)
+		}
+	}
+	return x, nil
+}
+
+// decodeGPS returns the location stored in the GPS directory, or nil if it
+// does not record coordinates.
+func decodeGPS(t *tiffReader, ifd tiffIFD) *GPSInfo {
+	lat, okLat := gpsCoordinate(t, ifd, gpsTagLatitude)
+	lon, okLon := gpsCoordinate(t, ifd, gpsTagLongitude)
+	if !okLat || !okLon {
+		return nil
+	}
+
+	gps := &GPSInfo{Latitude: lat, Longitude: lon}
+	if t.stringOf(ifd, gpsTagLatitudeRef) == "S" {
+		gps.Latitude = -gps.Latitude
+	}
+	if t.stringOf(ifd, gpsTagLongitudeRef) == "W" {
+		gps.Longitude = -gps.Longitude
+	}
+	if alt, ok := t.rationalOf(ifd, gpsTagAltitude, 0); ok {
+		gps.Altitude = alt.Float64()
+		if ref, ok := t.uintOf(ifd, gpsTagAltitudeRef, 0); ok && ref == 1 {
+			gps.Altitude = -gps.Altitude
+		}
+	}
+
+	date, err := time.Parse("2006:01:02", t.stringOf(ifd, gpsTagDateStamp))
+	if err == nil {
+		var clock [3]float64
+		for i := range clock {
+			v, _ := t.rationalOf(ifd, gpsTagTimeStamp, i)
+			clock[i] = v.Float64()
+		}
+		seconds := clock[0]*3600 + clock[1]*60 + clock[2]
+		gps.Time = date.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	return gps
+}
+
+// gpsCoordinate returns a coordinate stored as degrees, minutes and seconds.
+func gpsCoordinate(t *tiffReader, ifd tiffIFD, tag uint16) (float64, bool) {
+	var dms [3]float64
+	for i := range dms {
+		v, ok := t.rationalOf(ifd, tag, i)
+		if !ok {
+			return 0, false
+		}
+		dms[i] = v.Float64()
+	}
+	return dms[0] + dms[1]/60 + dms[2]/3600, true
+}
+
+// exifTimeLayout is the layout of EXIF date and time values.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// parseExifTime parses an EXIF date and time, with optional fractional
+// seconds and UTC offset. It returns the zero time if the value is empty or
+// invalid, as cameras record unknown dates as blanks or zeros.
+func parseExifTime(value, subSec, offset string) time.Time {
+	loc := time.Local
+	if offset != "" {
+		if t, err := time.Parse("-07:00", offset); err == nil {
+			_, seconds := t.Zone()
+			loc = time.FixedZone(offset, seconds)
+		}
+	}
+
+	t, err := time.ParseInLocation(exifTimeLayout, value, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	if subSec = strings.TrimSpace(subSec); subSec != "" {
+		if frac, err := time.ParseDuration("0." + subSec + "s"); err == nil {
+			t = t.Add(frac)
+		}
+	}
+	return t
+}
@@ -0,0 +1,296 @@
+package fs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tiffField is an entry of a directory built by buildTIFF. Its value is a
+// string, a []uint16, a []uint32 or a []Rational, or the index of another
+// directory it points to.
+type tiffField struct {
+	tag   uint16
+	value any
+}
+
+// buildTIFF builds a TIFF structure made of the given directories, the first
+// being IFD0.
+func buildTIFF(order binary.AppendByteOrder, ifds ...[]tiffField) []byte {
+	type encoded struct {
+		typ   uint16
+		count int
+		data  []byte
+	}
+	encode := func(f tiffField, offsets []uint32) encoded {
+		switch v := f.value.(type) {
+		case string:
+			return encoded{tiffASCII, len(v) + 1, append([]byte(v), 0)}
+		case []uint16:
+			var data []byte
+			for _, n := range v {
+				data = order.AppendUint16(data, n)
+			}
+			return encoded{tiffShort, len(v), data}
+		case []uint32:
+			var data []byte
+			for _, n := range v {
+				data = order.AppendUint32(data, n)
+			}
+			return encoded{tiffLong, len(v), data}
+		case []Rational:
+			var data []byte
+			for _, r := range v {
+				data = order.AppendUint32(data, uint32(r.Num))
+				data = order.AppendUint32(data, uint32(r.Den))
+			}
+			return encoded{tiffRational, len(v), data}
+		case int:
+			return encoded{tiffLong, 1, order.AppendUint32(nil, offsets[v])}
+		default:
+			panic("unsupported TIFF value")
+		}
+	}
+
+	offsets := make([]uint32, len(ifds))
+	pos := uint32(8)
+	for i, ifd := range ifds {
+		offsets[i] = pos
+		pos += 2 + 12*uint32(len(ifd)) + 4
+		for _, f := range ifd {
+			if e := encode(f, make([]uint32, len(ifds))); len(e.data) > 4 {
+				pos += uint32(len(e.data))
+			}
+		}
+	}
+
+	b := []byte("II*\x00")
+	if order == binary.BigEndian {
+		b = []byte("MM\x00*")
+	}
+	b = order.AppendUint32(b, 8)
+	for i, ifd := range ifds {
+		dataPos := offsets[i] + 2 + 12*uint32(len(ifd)) + 4
+		var data []byte
+		b = order.AppendUint16(b, uint16(len(ifd)))
+		for _, f := range ifd {
+			e := encode(f, offsets)
+			b = order.AppendUint16(b, f.tag)
+			b = order.AppendUint16(b, e.typ)
+			b = order.AppendUint32(b, uint32(e.count))
+			if len(e.data) <= 4 {
+				b = append(b, make([]byte, 4)...)
+				copy(b[len(b)-4:], e.data)
+			} else {
+				b = order.AppendUint32(b, dataPos+uint32(len(data)))
+				data = append(data, e.data...)
+			}
+		}
+		b = order.AppendUint32(b, 0)
+		b = append(b, data...)
+	}
+	return b
+}
+
+// buildJPEG builds a JPEG image of the given dimensions, with the TIFF
+// structure holding its EXIF metadata if not nil.
+func buildJPEG(tiff []byte, width, height int) []byte {
+	b := []byte{0xFF, 0xD8}
+	if tiff != nil {
+		app1 := append([]byte("Exif\x00\x00"), tiff...)
+		b = append(b, 0xFF, 0xE1)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(app1)+2))
+		b = append(b, app1...)
+	}
+	b = append(b, 0xFF, 0xC0, 0x00, 0x0B, 0x08)
+	b = binary.BigEndian.AppendUint16(b, uint16(height))
+	b = binary.BigEndian.AppendUint16(b, uint16(width))
+	b = append(b, 0x01, 0x01, 0x11, 0x00)
+	b = append(b, 0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00)
+	return append(b, 0x12, 0x34, 0xFF, 0xD9)
+}
+
+// testExifTIFF returns the EXIF metadata of a photo taken with a phone.
+func testExifTIFF() []byte {
+	return buildTIFF(binary.LittleEndian,
+		[]tiffField{
+			{exifTagImageWidth, []uint32{160}},
+			{exifTagImageHeight, []uint32{120}},
+			{exifTagMake, "Google"},
+			{exifTagModel, "Pixel 7"},
+			{exifTagOrientation, []uint16{6}},
+			{exifTagSoftware, "HDR+ 1.0"},
+			{exifTagDateTime, "2023:05:14 10:15:30"},
+			{exifTagExifIFD, 1},
+			{exifTagGPSIFD, 2},
+		},
+		[]tiffField{
+			{exifTagExposureTime, []Rational{{1, 250}}},
+			{exifTagFNumber, []Rational{{185, 100}}},
+			{exifTagISO, []uint16{100}},
+			{exifTagDateTimeOriginal, "2023:05:14 10:15:30"},
+			{exifTagDateTimeDigitized, "2023:05:14 10:15:30"},
+			{exifTagOffsetTimeOriginal, "+02:00"},
+			{exifTagSubSecTimeOriginal, "25"},
+			{exifTagFocalLength, []Rational{{681, 100}}},
+			{exifTagPixelXDimension, []uint32{4080}},
+			{exifTagPixelYDimension, []uint32{3072}},
+			{exifTagFocalLength35mm, []uint16{24}},
+			{exifTagLensMake, "Google"},
+			{exifTagLensModel, "Pixel 7 back camera 6.81mm f/1.85"},
+		},
+		[]tiffField{
+			{gpsTagLatitudeRef, "N"},
+			{gpsTagLatitude, []Rational{{48, 1}, {51, 1}, {2376, 100}}},
+			{gpsTagLongitudeRef, "W"},
+			{gpsTagLongitude, []Rational{{2, 1}, {21, 1}, {756, 100}}},
+			{gpsTagAltitudeRef, []uint16{0}},
+			{gpsTagAltitude, []Rational{{35, 1}}},
+			{gpsTagTimeStamp, []Rational{{8, 1}, {15, 1}, {29, 1}}},
+			{gpsTagDateStamp, "2023:05:14"},
+		},
+	)
+}
+
+func TestReadExif(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	require.NoError(t, os.WriteFile(path, buildJPEG(testExifTIFF(), 16, 12), 0644))
+
+	x, err := ReadExif(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Google", x.Make)
+	assert.Equal(t, "Pixel 7", x.Model)
+	assert.Equal(t, "Google", x.LensMake)
+	assert.Equal(t, "Pixel 7 back camera 6.81mm f/1.85", x.LensModel)
+	assert.Equal(t, "HDR+ 1.0", x.Software)
+
+	zone := time.FixedZone("+02:00", 2*60*60)
+	assert.Equal(t, "+02:00", x.OffsetTime)
+	assert.True(t, x.DateTimeOriginal.Equal(time.Date(2023, 5, 14, 10, 15, 30, 250e6, zone)), x.DateTimeOriginal)
+	_, offset := x.DateTimeOriginal.Zone()
+	assert.Equal(t, 2*60*60, offset)
+	assert.Equal(t, time.Date(2023, 5, 14, 10, 15, 30, 0, time.Local), x.DateTimeDigitized)
+	assert.Equal(t, time.Date(2023, 5, 14, 10, 15, 30, 0, time.Local), x.DateTime)
+
+	assert.Equal(t, Rational{1, 250}, x.ExposureTime)
+	assert.Equal(t, "1/250", x.ExposureTime.String())
+	assert.InDelta(t, 1.85, x.FNumber, 1e-9)
+	assert.Equal(t, 100, x.ISO)
+	assert.InDelta(t, 6.81, x.FocalLength, 1e-9)
+	assert.Equal(t, 24, x.FocalLength35mm)
+
+	assert.Equal(t, 6, x.Orientation)
+	assert.Equal(t, 4080, x.Width)
+	assert.Equal(t, 3072, x.Height)
+
+	require.NotNil(t, x.GPS)
+	assert.InDelta(t, 48.8566, x.GPS.Latitude, 1e-6)
+	assert.InDelta(t, -2.3521, x.GPS.Longitude, 1e-6)
+	assert.InDelta(t, 35, x.GPS.Altitude, 1e-9)
+	assert.Equal(t, time.Date(2023, 5, 14, 8, 15, 29, 0, time.UTC), x.GPS.Time)
+}
+
+func TestReadExifFormats(t *testing.T) {
+	tiff := buildTIFF(binary.BigEndian,
+		[]tiffField{
+			{exifTagImageWidth, []uint16{640}},
+			{exifTagImageHeight, []uint16{480}},
+			{exifTagMake, "Canon"},
+		},
+	)
+
+	png := []byte("\x89PNG\r\n\x1A\n")
+	png = append(png, "\x00\x00\x00\x0DIHDR\x00\x00\x02\x80\x00\x00\x01\xE0\x08\x02\x00\x00\x00\x00\x00\x00\x00"...)
+	png = binary.BigEndian.AppendUint32(png, uint32(len(tiff)))
+	png = append(append(append(png, "eXIf"...), tiff...), 0, 0, 0, 0)
+	png = append(png, "\x00\x00\x00\x00IEND\xAE\x42\x60\x82"...)
+
+	webp := []byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0A\x00\x00\x00\x08\x00\x00\x00\x7F\x02\x00\xDF\x01\x00")
+	webp = append(webp, "EXIF"...)
+	webp = binary.LittleEndian.AppendUint32(webp, uint32(len(tiff)+6))
+	webp = append(append(webp, "Exif\x00\x00"...), tiff...)
+
+	raf := append([]byte("FUJIFILMCCD-RAW 0201"), make([]byte, 72)...)
+	jpeg := buildJPEG(tiff, 640, 480)
+	binary.BigEndian.PutUint32(raf[84:], uint32(len(raf)))
+	binary.BigEndian.PutUint32(raf[88:], uint32(len(jpeg)))
+	raf = append(raf, jpeg...)
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"photo.tif", tiff},
+		{"photo.png", png},
+		{"photo.webp", webp},
+		{"photo.raf", raf},
+	}
+	dir := t.TempDir()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			require.NoError(t, os.WriteFile(path, tc.data, 0644))
+
+			x, err := ReadExif(path)
+			require.NoError(t, err)
+			assert.Equal(t, "Canon", x.Make)
+			assert.Equal(t, 640, x.Width)
+			assert.Equal(t, 480, x.Height)
+			assert.Nil(t, x.GPS)
+		})
+	}
+}
+
+func TestReadExifErrors(t *testing.T) {
+	dir := t.TempDir()
+	createTree(t, dir, map[string]string{
+		"notes.txt":     "not a photo",
+		"plain.jpg":     string(buildJPEG(nil, 16, 12)),
+		"corrupt.jpg":   string(buildJPEG([]byte("II*\x00\xFF\xFF\xFF\xFF"), 16, 12)),
+		"truncated.jpg": string(buildJPEG(testExifTIFF(), 16, 12)[:40]),
+	})
+
+	_, err := ReadExif(filepath.Join(dir, "notes.txt"))
+	assert.ErrorIs(t, err, ErrNoExif)
+
+	_, err = ReadExif(filepath.Join(dir, "plain.jpg"))
+	assert.ErrorIs(t, err, ErrNoExif)
+
+	_, err = ReadExif(filepath.Join(dir, "corrupt.jpg"))
+	assert.ErrorIs(t, err, errInvalidTIFF)
+
+	_, err = ReadExif(filepath.Join(dir, "truncated.jpg"))
+	assert.Error(t, err)
+
+	_, err = ReadExif(filepath.Join(dir, "missing.jpg"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileInfoExif(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	require.NoError(t, os.WriteFile(path, buildJPEG(testExifTIFF(), 16, 12), 0644))
+
+	info, err := NewFileInfo(path)
+	require.NoError(t, err)
+	x, err := info.Exif()
+	require.NoError(t, err)
+	assert.Equal(t, "Pixel 7", x.Model)
+
+	// The metadata is cached.
+	require.NoError(t, os.WriteFile(path, buildJPEG(nil, 16, 12), 0644))
+	cached, err := info.Exif()
+	require.NoError(t, err)
+	assert.Same(t, x, cached)
+
+	dirInfo, err := NewFileInfo(dir)
+	require.NoError(t, err)
+	_, err = dirInfo.Exif()
+	assert.ErrorIs(t, err, ErrNoExif)
+}
@@ -0,0 +1,42 @@
+package fs
+
+import "fmt"
+
+// This file defines FileID, a stable identity for files that does not depend
+// on their path. It allows recognizing that a file was moved or renamed
+// within a file system rather than deleted and re-created.
+
+// FileID identifies a file within the system, independently of its path.
+// On Unix and Darwin, it is made of the device and inode numbers. On Windows,
+// it is made of the volume serial number and the file index. Two paths with
+// equal FileIDs refer to the same file, e.g. through hard links, as long as
+// the file is not deleted in between, since file systems reuse inode numbers.
+type FileID struct {
+	Dev uint64 // device, or volume serial number on Windows
+	Ino uint64 // inode number, or file index on Windows
+}
+
+// IsZero reports whether the FileID is unknown.
+func (id FileID) IsZero() bool {
+	return id == FileID{}
+}
+
+// Equal reports whether id and other identify the same file.
+// Unknown FileIDs are never equal.
+func (id FileID) Equal(other FileID) bool {
+	return !id.IsZero() && id == other
+}
+
+// String returns the FileID formatted as "dev:ino" in hexadecimal.
+func (id FileID) String() string {
+	return fmt.Sprintf("%x:%x", id.Dev, id.Ino)
+}
+
+// SameFile reports whether a and b describe the same file, even if they were
+// obtained through different paths.
+func SameFile(a, b FileInfo) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return a.FileID().Equal(b.FileID())
+}
@@ -0,0 +1,56 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileID(t *testing.T) {
+	dir := t.TempDir()
+	createTree(t, dir, map[string]string{
+		"a.jpg": "same content",
+		"b.jpg": "same content",
+	})
+
+	a, err := NewFileInfo(filepath.Join(dir, "a.jpg"))
+	require.NoError(t, err)
+	b, err := NewFileInfo(filepath.Join(dir, "b.jpg"))
+	require.NoError(t, err)
+
+	assert.False(t, a.FileID().IsZero())
+	assert.False(t, a.FileID().Equal(b.FileID()))
+	assert.False(t, SameFile(a, b))
+
+	t.Run("rename", func(t *testing.T) {
+		renamed := filepath.Join(dir, "renamed.jpg")
+		require.NoError(t, os.Rename(filepath.Join(dir, "b.jpg"), renamed))
+
+		info, err := NewFileInfo(renamed)
+		require.NoError(t, err)
+		assert.True(t, info.FileID().Equal(b.FileID()))
+		assert.True(t, SameFile(info, b))
+	})
+
+	t.Run("hard link", func(t *testing.T) {
+		linked := filepath.Join(dir, "linked.jpg")
+		if err := os.Link(filepath.Join(dir, "a.jpg"), linked); err != nil {
+			t.Skip("Skipping hard link test because hard link could not be created")
+		}
+
+		info, err := NewFileInfo(linked)
+		require.NoError(t, err)
+		assert.Equal(t, a.FileID(), info.FileID())
+		assert.True(t, SameFile(a, info))
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		assert.True(t, FileID{}.IsZero())
+		assert.False(t, FileID{}.Equal(FileID{}))
+		assert.False(t, SameFile(a, nil))
+		assert.Equal(t, "2a:ff", FileID{Dev: 42, Ino: 255}.String())
+	})
+}
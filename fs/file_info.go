@@ -1,10 +1,13 @@
 package fs
 
 import (
+	"context"
+	"fmt"
 	gofs "io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,35 +20,104 @@ import (
 // It extends the standard go fs.FileInfo interface with additional methods
 // to retrieve file path, title, extension, and various timestamps.
 type FileInfo interface {
-	Name() string  // base name of the file (excluding the path)
-	Path() string  // path to the file (excluding the base name)
-	Abs() string   // absolute path to the file
-	Title() string // title of the file
-	Ext() string   // extension of the file
-	Size() int64   // length in bytes for regular files; system-dependent for others
-	IsDir() bool   // abbreviation for Mode().IsDir()
-
-	CreationTime() time.Time   // creation time
+	Name() string         // base name of the file (excluding the path)
+	Path() string         // path to the file (excluding the base name)
+	Abs() string          // absolute path to the file
+	Title() string        // title of the file
+	Ext() string          // extension of the file
+	Kind() Kind           // media kind of the file, derived from its extension
+	Size() int64          // length in bytes for regular files; system-dependent for others
+	AllocatedSize() int64 // space allocated on disk, in bytes
+	IsSparse() bool       // whether the file contains unallocated holes
+	IsDir() bool          // abbreviation for Mode().IsDir()
+	FileID() FileID       // identity of the file, independent of its path
+	LinkCount() uint64    // number of hard links to the file
+
+	Xattrs() (map[string][]byte, error) // extended attributes, loaded on first use
+	Exif() (*Exif, error)               // EXIF metadata, loaded on first use
+	Movie() (*Movie, error)             // QuickTime and MP4 metadata, loaded on first use
+	HEIF() (*HEIF, error)               // HEIC, HEIF and AVIF image description, loaded on first use
+
+	CreationTime() time.Time   // creation time, falling back to the change time
+	HasCreationTime() bool     // whether CreationTime is the genuine creation time
 	LastAccessTime() time.Time // last access time
 	LastWriteTime() time.Time  // last write time
+	ChangeTime() time.Time     // last status change time, zero if not available
+	BirthTime() time.Time      // birth time, zero if not available
+	Timestamps() Timestamps    // timestamps provided by the platform and file system
+
+	IsSymlink() bool    // whether the file is a symbolic link
+	LinkTarget() string // target of the symbolic link, as stored in the link
+	Target() FileInfo   // file the symbolic link points to, nil if dangling
+	IsDangling() bool   // whether the symbolic link points to a missing file
+}
+
+// Timestamps is a set of flags identifying the timestamps of a file.
+// It reports which timestamps are actually provided by the platform and the
+// file system, so that callers can build correct fallback chains.
+type Timestamps uint8
+
+const (
+	TimestampAccess Timestamps = 1 << iota // last access time
+	TimestampWrite                         // last write (modification) time
+	TimestampChange                        // last status change time
+	TimestampBirth                         // birth (creation) time
+)
+
+// Has reports whether all the timestamps in flags are in the set.
+func (t Timestamps) Has(flags Timestamps) bool {
+	return t&flags == flags
+}
+
+// SysInfo is a portable representation of the system-dependent data
+// describing a file. It is returned by the Sys method of FileInfo values
+// created by this package. Fields that are not provided by the platform are
+// left zero.
+type SysInfo struct {
+	Dev        uint64 // device containing the file
+	Ino        uint64 // inode number
+	Nlink      uint64 // number of hard links
+	Uid        uint32 // user ID of the owner
+	Gid        uint32 // group ID of the owner
+	Rdev       uint64 // device ID, for special files
+	Blksize    int64  // preferred block size for file system I/O
+	Blocks     int64  // number of 512-byte blocks allocated
+	Attributes uint32 // file attributes, on Windows
+
+	// Raw is the underlying data source, as returned by os.FileInfo.Sys:
+	// *syscall.Stat_t on Unix and Darwin, *syscall.Win32FileAttributeData on Windows.
+	Raw any
 }
 
 // fileInfo is a structure that contains information about a file.
 // It implements the FileInfo interface and the go fs.FileInfo interface,
 // providing a concrete representation of file metadata.
 type fileInfo struct {
-	name  string
-	path  string
-	abs   string
-	title string
-	ext   string
-	size  int64
-	mode  gofs.FileMode
-	dir   bool
-
-	creationTime   time.Time
+	name      string
+	path      string
+	abs       string
+	title     string
+	ext       string
+	size      int64
+	allocated int64
+	mode      gofs.FileMode
+	dir       bool
+	sparse    bool
+	sys       *SysInfo
+
 	lastAccessTime time.Time
 	lastWriteTime  time.Time
+	changeTime     time.Time
+	birthTime      time.Time
+	timestamps     Timestamps
+
+	linkTarget string
+	target     *fileInfo
+
+	xattrs *lazy[map[string][]byte]
+	exif   *lazy[*Exif]
+	movie  *lazy[*Movie]
+	heif   *lazy[*HEIF]
 }
 
 // fileInfo should implement the FileInfo interface
@@ -54,10 +126,46 @@ var _ FileInfo = (*fileInfo)(nil)
 // fileInfo should implement the FileInfo interface
 var _ gofs.FileInfo = (*fileInfo)(nil)
 
+// lazy holds a value computed on first use, such as metadata that is costly
+// to read and not needed for every file. It is shared by the copies of the
+// fileInfo holding it.
+type lazy[T any] struct {
+	once  sync.Once
+	value T
+	err   error
+}
+
+// get returns the value, computing it with load on the first call only.
+func (l *lazy[T]) get(load func() (T, error)) (T, error) {
+	l.once.Do(func() {
+		l.value, l.err = load()
+	})
+	return l.value, l.err
+}
+
+// FileInfoOption configures how NewFileInfo and NewFileInfoLstat describe a file.
+type FileInfoOption func(*fileInfoOptions)
+
+// fileInfoOptions holds the options applied by FileInfoOption values.
+type fileInfoOptions struct {
+	dirSize *DirSizeOptions
+}
+
+// WithDirSize makes Size report, for directories, the apparent size of all
+// the files they contain, computed recursively with DirSize. Without it, the
+// size of a directory is the size of the directory entry itself, as reported
+// by GetSize.
+// Errors reading part of the directory are ignored.
+func WithDirSize(opts DirSizeOptions) FileInfoOption {
+	return func(o *fileInfoOptions) {
+		o.dirSize = &opts
+	}
+}
+
 // NewFileInfo creates a new FileInfo struct.
 // It takes a file path as input, retrieves the file's metadata using os.Stat,
 // and returns a fileInfo object populated with this metadata.
-func NewFileInfo(path string) (*fileInfo, error) {
+func NewFileInfo(path string, opts ...FileInfoOption) (*fileInfo, error) {
 	resolvedPath, err := Resolve(path)
 	if err != nil {
 		return nil, err
@@ -67,7 +175,55 @@ func NewFileInfo(path string) (*fileInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newFileInfoFromFileInfo(info, resolvedPath)
+
+	f, err := newFileInfoFromFileInfo(info, resolvedPath)
+	if err != nil {
+		return nil, err
+	}
+	f.apply(opts)
+	return f, nil
+}
+
+// NewFileInfoLstat creates a new FileInfo struct without following symbolic
+// links. Unlike NewFileInfo, it retrieves the metadata using os.Lstat, so a
+// symbolic link is described as a link rather than as its target: IsSymlink
+// reports true, LinkTarget returns the path stored in the link and Target
+// describes the file it points to. A link whose target cannot be resolved,
+// because it does not exist, forms a loop or is inaccessible, is reported as
+// dangling instead of returning an error.
+func NewFileInfoLstat(path string, opts ...FileInfoOption) (*fileInfo, error) {
+	absPath, err := absolute(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := newFileInfoFromFileInfo(info, absPath)
+	if err != nil {
+		return nil, err
+	}
+	f.apply(opts)
+	if !f.IsSymlink() {
+		return f, nil
+	}
+
+	f.linkTarget, err = os.Readlink(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	targetPath := f.linkTarget
+	if !filepath.IsAbs(targetPath) {
+		targetPath = filepath.Join(f.path, targetPath)
+	}
+	if target, err := NewFileInfo(targetPath, opts...); err == nil {
+		f.target = target
+	}
+	return f, nil
 }
 
 // newFileInfoFromFileInfo creates a new fileInfo struct from an existing os.FileInfo object.
@@ -82,15 +238,43 @@ func newFileInfoFromFileInfo(info os.FileInfo, absPath string) (*fileInfo, error
 	f.title = strings.TrimSuffix(f.name, f.ext)
 	f.mode = info.Mode()
 	f.dir = isDir(info)
+	f.sys = newSysInfo(info, absPath)
+	f.xattrs = &lazy[map[string][]byte]{}
+	f.exif = &lazy[*Exif]{}
+	f.movie = &lazy[*Movie]{}
+	f.heif = &lazy[*HEIF]{}
 
 	f.size = GetSize(info, absPath)
+	f.allocated = getAllocatedSize(info, absPath)
+	f.sparse = isSparse(info, absPath, f.allocated)
 
-	f.creationTime = getCreationTime(info)
 	f.lastAccessTime = getLastAccessTime(info)
 	f.lastWriteTime = getLastWriteTime(info)
+	f.timestamps = TimestampAccess | TimestampWrite
+
+	var ok bool
+	if f.changeTime, ok = getChangeTime(info, absPath); ok {
+		f.timestamps |= TimestampChange
+	}
+	if f.birthTime, ok = getBirthTime(info, absPath); ok {
+		f.timestamps |= TimestampBirth
+	}
 	return &f, nil
 }
 
+// apply applies the options to the fileInfo.
+func (f *fileInfo) apply(opts []FileInfoOption) {
+	var o fileInfoOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.dirSize != nil && f.dir {
+		usage, _ := DirSize(context.Background(), f.abs, *o.dirSize)
+		f.size = usage.Apparent
+	}
+}
+
 // Name returns the base name of the file.
 func (f fileInfo) Name() string {
 	return f.name
@@ -116,11 +300,35 @@ func (f fileInfo) Ext() string {
 	return f.ext
 }
 
+// Kind returns the media kind of the file, as registered for its extension.
+// Directories are always of kind KindOther.
+func (f fileInfo) Kind() Kind {
+	if f.dir {
+		return KindOther
+	}
+	return KindOf(f.ext)
+}
+
 // Size returns the length in bytes for regular files; system-dependent for others.
 func (f fileInfo) Size() int64 {
 	return f.size
 }
 
+// AllocatedSize returns the space allocated on disk for the file, in bytes.
+// It may be smaller than Size for sparse or compressed files, and larger for
+// files whose size is not a multiple of the file system block size. On
+// Windows, it is the size reported by GetCompressedFileSize, which is not
+// rounded to the cluster size.
+func (f fileInfo) AllocatedSize() int64 {
+	return f.allocated
+}
+
+// IsSparse reports whether the file is sparse, i.e. contains holes that are
+// not allocated on disk.
+func (f fileInfo) IsSparse() bool {
+	return f.sparse
+}
+
 // Mode returns the file mode bits.
 func (f fileInfo) Mode() gofs.FileMode {
 	return f.mode
@@ -136,16 +344,88 @@ func (f fileInfo) IsDir() bool {
 	return f.dir
 }
 
-// Sys returns the underlying data source.
-// This is a placeholder implementation and can be modified to return
-// actual underlying data if needed.
+// FileID returns the identity of the file, which does not change when the
+// file is renamed or moved within the same file system.
+func (f fileInfo) FileID() FileID {
+	return FileID{Dev: f.sys.Dev, Ino: f.sys.Ino}
+}
+
+// LinkCount returns the number of hard links to the file, or 0 if the
+// platform does not provide it.
+func (f fileInfo) LinkCount() uint64 {
+	return f.sys.Nlink
+}
+
+// Xattrs returns the extended attributes of the file, following symbolic
+// links, by name. They are read on the first call and cached; use GetXattr
+// to read the current value of an attribute. It returns an error wrapping
+// ErrXattrUnsupported if the platform or the file system does not support
+// extended attributes. The returned map must not be modified.
+func (f fileInfo) Xattrs() (map[string][]byte, error) {
+	return f.xattrs.get(func() (map[string][]byte, error) {
+		return readXattrs(f.abs)
+	})
+}
+
+// Exif returns the EXIF metadata of the file, following symbolic links. It is
+// read on the first call and cached. It returns an error wrapping ErrNoExif if
+// the file has none, including directories and files that are not photos.
+func (f fileInfo) Exif() (*Exif, error) {
+	return f.exif.get(func() (*Exif, error) {
+		if f.dir {
+			return nil, fmt.Errorf("%s: %w", f.abs, ErrNoExif)
+		}
+		return ReadExif(f.abs)
+	})
+}
+
+// Movie returns the metadata of the file if it is a QuickTime or MP4 movie,
+// such as a .mov, .mp4, .m4v or .3gp file, following symbolic links. It is
+// read on the first call and cached. It returns an error wrapping ErrNoMovie
+// for other files, including directories.
+func (f fileInfo) Movie() (*Movie, error) {
+	return f.movie.get(func() (*Movie, error) {
+		if f.dir {
+			return nil, fmt.Errorf("%s: %w", f.abs, ErrNoMovie)
+		}
+		return ReadMovie(f.abs)
+	})
+}
+
+// HEIF returns the description of the file if it is a HEIF image, such as a
+// .heic or .avif file, following symbolic links. It is read on the first call
+// and cached. It returns an error wrapping ErrNoHEIF for other files,
+// including directories.
+func (f fileInfo) HEIF() (*HEIF, error) {
+	return f.heif.get(func() (*HEIF, error) {
+		if f.dir {
+			return nil, fmt.Errorf("%s: %w", f.abs, ErrNoHEIF)
+		}
+		return ReadHEIF(f.abs)
+	})
+}
+
+// Sys returns the underlying data source as a *SysInfo.
+// The raw value returned by os.FileInfo.Sys is available in its Raw field.
 func (f fileInfo) Sys() any {
-	return nil
+	return f.sys
 }
 
-// CreationTime returns the creation time.
+// CreationTime returns the creation time. It is the birth time of the file
+// when available, and the last status change time otherwise.
 func (f fileInfo) CreationTime() time.Time {
-	return f.creationTime
+	if f.timestamps.Has(TimestampBirth) {
+		return f.birthTime
+	}
+	return f.changeTime
+}
+
+// HasCreationTime reports whether CreationTime returns the genuine creation
+// (birth) time of the file. It is false when the platform or the file system
+// does not record it, in which case CreationTime falls back to the last
+// status change time.
+func (f fileInfo) HasCreationTime() bool {
+	return f.timestamps.Has(TimestampBirth)
 }
 
 // LastAccessTime returns the last access time.
@@ -157,3 +437,52 @@ func (f fileInfo) LastAccessTime() time.Time {
 func (f fileInfo) LastWriteTime() time.Time {
 	return f.lastWriteTime
 }
+
+// ChangeTime returns the last status change time, which is updated whenever
+// the content or the metadata of the file changes. It returns the zero time
+// if the platform or the file system does not provide it.
+func (f fileInfo) ChangeTime() time.Time {
+	return f.changeTime
+}
+
+// BirthTime returns the birth time of the file. Unlike CreationTime, it
+// returns the zero time if the platform or the file system does not provide it.
+func (f fileInfo) BirthTime() time.Time {
+	return f.birthTime
+}
+
+// IsSymlink reports whether the file is a symbolic link. It is only true for
+// FileInfo values that do not follow links, such as those created with
+// NewFileInfoLstat.
+func (f fileInfo) IsSymlink() bool {
+	return f.mode&os.ModeSymlink != 0
+}
+
+// LinkTarget returns the target of the symbolic link, as stored in the link.
+// It may be a relative path. It returns an empty string if the file is not
+// a symbolic link.
+func (f fileInfo) LinkTarget() string {
+	return f.linkTarget
+}
+
+// Target returns the FileInfo of the file the symbolic link points to, with
+// all links resolved. It returns nil if the file is not a symbolic link or if
+// the link is dangling.
+func (f fileInfo) Target() FileInfo {
+	if f.target == nil {
+		return nil
+	}
+	return f.target
+}
+
+// IsDangling reports whether the file is a symbolic link whose target cannot
+// be resolved.
+func (f fileInfo) IsDangling() bool {
+	return f.IsSymlink() && f.target == nil
+}
+
+// Timestamps returns the set of timestamps provided by the platform and the
+// file system for this file.
+func (f fileInfo) Timestamps() Timestamps {
+	return f.timestamps
+}
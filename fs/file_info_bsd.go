@@ -0,0 +1,25 @@
+//go:build freebsd || netbsd || openbsd
+
+package fs
+
+import (
+	"os"
+	"time"
+)
+
+// This file provides BSD-specific implementations for retrieving file
+// timestamps that are not shared with the other Unix-like systems.
+
+// getBirthTime returns the birth time of a file and whether it is available.
+// The birth time is not retrieved on BSD systems.
+func getBirthTime(info os.FileInfo, path string) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// isSparse reports whether a file is sparse, i.e. contains holes that are
+// not allocated on disk. On BSD systems, a regular file is considered sparse
+// when it uses fewer blocks than its size, which may also be the case for
+// files compressed by the file system.
+func isSparse(info os.FileInfo, path string, allocated int64) bool {
+	return info.Mode().IsRegular() && allocated < info.Size()
+}
@@ -4,6 +4,7 @@ package fs
 
 import (
 	"os"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -11,11 +12,23 @@ import (
 // This file provides Darwin-specific (macOS) implementations for retrieving
 // file timestamps. It is part of a cross-platform file system utility package.
 
-// getCreationTime returns the creation time (birth time) of a file.
-// On Darwin, this is accessed via the `Birthtimespec` field of `syscall.Stat_t`.
-func getCreationTime(info os.FileInfo) time.Time {
+// ufHidden is the `UF_HIDDEN` file flag, which hints that the file should
+// not be displayed in a GUI.
+const ufHidden = 0x8000
+
+// getBirthTime returns the birth time of a file and whether it is available.
+// On Darwin, this is accessed via the `Birthtimespec` field of `syscall.Stat_t`
+// and is always available.
+func getBirthTime(info os.FileInfo, path string) (time.Time, bool) {
 	stat := info.Sys().(*syscall.Stat_t)
-	return time.Unix(stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec)
+	return time.Unix(stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec), true
+}
+
+// getChangeTime returns the last status change time of a file and whether
+// it is available. It uses the `Ctimespec` field from `syscall.Stat_t`.
+func getChangeTime(info os.FileInfo, path string) (time.Time, bool) {
+	stat := info.Sys().(*syscall.Stat_t)
+	return time.Unix(stat.Ctimespec.Sec, stat.Ctimespec.Nsec), true
 }
 
 // getLastAccessTime retrieves the last access time of a file.
@@ -32,9 +45,52 @@ func getLastWriteTime(info os.FileInfo) time.Time {
 	return time.Unix(stat.Mtimespec.Sec, stat.Mtimespec.Nsec)
 }
 
-// GetSize returns the size of a file or directory. On Darwin, the size
-// reported by `os.FileInfo` is accurate for both files and directories,
-// so this function simply returns that value.
+// GetSize returns the size of a file or directory, as reported by
+// `os.FileInfo`. For directories, this is the size of the directory entry
+// itself, which depends on the number of entries it holds.
+// Use DirSize for a recursive directory size consistent across platforms.
 func GetSize(info os.FileInfo, path string) int64 {
 	return info.Size()
 }
+
+// isHidden reports whether a file is hidden. On Darwin, a file is hidden
+// when its name starts with a dot or when the `UF_HIDDEN` flag is set, which
+// is what the Finder uses to hide files.
+func isHidden(info os.FileInfo) bool {
+	if strings.HasPrefix(info.Name(), ".") {
+		return true
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Flags&ufHidden != 0
+}
+
+// newSysInfo returns the portable system-dependent data of a file.
+// It is filled from the `syscall.Stat_t` structure obtained by os.Stat.
+func newSysInfo(info os.FileInfo, path string) *SysInfo {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return &SysInfo{Raw: info.Sys()}
+	}
+	return &SysInfo{
+		Dev:     uint64(stat.Dev),
+		Ino:     stat.Ino,
+		Nlink:   uint64(stat.Nlink),
+		Uid:     stat.Uid,
+		Gid:     stat.Gid,
+		Rdev:    uint64(stat.Rdev),
+		Blksize: int64(stat.Blksize),
+		Blocks:  stat.Blocks,
+		Raw:     stat,
+	}
+}
+
+// getAllocatedSize returns the space allocated on disk for a file, in bytes.
+// It is computed from the `Blocks` field of `syscall.Stat_t`, which counts
+// 512-byte blocks regardless of the file system block size.
+func getAllocatedSize(info os.FileInfo, path string) int64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size()
+	}
+	return stat.Blocks * 512
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package fs
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// This file provides Linux-specific implementations for retrieving file
+// timestamps that are not available through `syscall.Stat_t`.
+
+// getBirthTime returns the birth time of a file and whether it is available.
+// The birth time is not part of `syscall.Stat_t` on Linux, so it is queried
+// with statx(2) and `STATX_BTIME`. Older kernels and some file systems do
+// not provide it.
+func getBirthTime(info os.FileInfo, path string) (time.Time, bool) {
+	flags := unix.AT_STATX_SYNC_AS_STAT
+	if info.Mode()&os.ModeSymlink != 0 {
+		flags |= unix.AT_SYMLINK_NOFOLLOW
+	}
+
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, path, flags, unix.STATX_BTIME, &stx)
+	if err == nil && stx.Mask&unix.STATX_BTIME != 0 {
+		return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,32 @@
+//go:build linux || darwin
+
+package fs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// This file provides sparse file detection for the systems supporting the
+// `SEEK_HOLE` option of lseek(2).
+
+// isSparse reports whether a file is sparse, i.e. contains holes that are
+// not allocated on disk. A regular file using fewer blocks than its size is
+// a candidate, which is then confirmed by looking for a hole with lseek(2)
+// and `SEEK_HOLE`, to rule out compressed files and files whose data is
+// stored inline in the inode.
+func isSparse(info os.FileInfo, path string, allocated int64) bool {
+	if !info.Mode().IsRegular() || allocated >= info.Size() {
+		return false
+	}
+
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return false
+	}
+	defer unix.Close(fd)
+
+	hole, err := unix.Seek(fd, 0, unix.SEEK_HOLE)
+	return err == nil && hole < info.Size()
+}
@@ -4,7 +4,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -251,3 +253,255 @@ func TestNewFileInfo(t *testing.T) {
 		assert.Equal(t, false, info.IsDir())
 	})
 }
+
+// TestCreationTime checks that a genuine creation time is not affected by
+// metadata changes, unlike the status change time used as a fallback.
+func TestCreationTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, []byte("photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := NewFileInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !before.HasCreationTime() {
+		t.Skip("Skipping creation time test because the file system does not record it")
+	}
+	assert.False(t, before.CreationTime().IsZero())
+	assert.False(t, before.CreationTime().After(before.LastWriteTime()))
+
+	time.Sleep(20 * time.Millisecond)
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := NewFileInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, before.CreationTime(), after.CreationTime())
+}
+
+// TestTimestamps checks that the reported timestamps are consistent with the
+// set of timestamps the platform and file system provide.
+func TestTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, []byte("photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := NewFileInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamps := info.Timestamps()
+	assert.True(t, timestamps.Has(TimestampAccess|TimestampWrite))
+	assert.Equal(t, timestamps.Has(TimestampChange), !info.ChangeTime().IsZero())
+	assert.Equal(t, timestamps.Has(TimestampBirth), !info.BirthTime().IsZero())
+	assert.Equal(t, timestamps.Has(TimestampBirth), info.HasCreationTime())
+	if info.HasCreationTime() {
+		assert.Equal(t, info.BirthTime(), info.CreationTime())
+	} else {
+		assert.Equal(t, info.ChangeTime(), info.CreationTime())
+	}
+
+	if !timestamps.Has(TimestampChange) {
+		return
+	}
+
+	// The change time follows metadata changes, unlike the write time.
+	time.Sleep(20 * time.Millisecond)
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	after, err := NewFileInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, after.ChangeTime().After(info.ChangeTime()))
+	assert.Equal(t, info.LastWriteTime(), after.LastWriteTime())
+}
+
+// TestSys checks that Sys returns the portable system-dependent data and
+// keeps the raw value returned by os.Stat.
+func TestSys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, []byte("photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := NewFileInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sys, ok := info.Sys().(*SysInfo)
+	if !ok {
+		t.Fatalf("Sys() returned %T, want *SysInfo", info.Sys())
+	}
+	assert.NotNil(t, sys.Raw)
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.IsType(t, stat.Sys(), sys.Raw)
+
+	if runtime.GOOS != "windows" {
+		assert.NotZero(t, sys.Ino)
+		assert.Equal(t, uint64(1), sys.Nlink)
+		assert.Equal(t, uint32(os.Getuid()), sys.Uid)
+	}
+}
+
+// TestNewFileInfoLstat contains a suite of sub-tests for the NewFileInfoLstat
+// function, covering regular files and symbolic links to files, directories
+// and missing targets.
+func TestNewFileInfoLstat(t *testing.T) {
+	// Test case for a regular file, which is described as with NewFileInfo.
+	t.Run("directory_text.txt", func(t *testing.T) {
+		path := filepath.Join("testdata", "directory", "text.txt")
+
+		info, err := NewFileInfoLstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "text.txt", info.Name())
+		assert.Equal(t, int64(24), info.Size())
+		assert.False(t, info.IsSymlink())
+		assert.False(t, info.IsDangling())
+		assert.Equal(t, "", info.LinkTarget())
+		assert.Nil(t, info.Target())
+	})
+
+	// Test case for a symbolic link to a directory, which must not be
+	// reported as a directory itself.
+	t.Run("linked", func(t *testing.T) {
+		if !symlinkCreated {
+			t.Skip("Skipping symlink test because symlink could not be created")
+		}
+		path := filepath.Join("testdata", "linked")
+
+		info, err := NewFileInfoLstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targetPath, err := Resolve(filepath.Join("testdata", "directory"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "linked", info.Name())
+		assert.Equal(t, absPath, info.Abs())
+		assert.True(t, info.IsSymlink())
+		assert.False(t, info.IsDir())
+		assert.False(t, info.IsDangling())
+		if assert.NotNil(t, info.Target()) {
+			assert.Equal(t, targetPath, info.Target().Abs())
+			assert.True(t, info.Target().IsDir())
+		}
+	})
+
+	// Test case for a relative symbolic link to a file.
+	t.Run("file link", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "photo.jpg"), []byte("photo"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "alias.jpg")
+		if err := os.Symlink("photo.jpg", path); err != nil {
+			t.Skip("Skipping symlink test because symlink could not be created")
+		}
+
+		info, err := NewFileInfoLstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "alias", info.Title())
+		assert.True(t, info.IsSymlink())
+		assert.False(t, info.IsDir())
+		assert.Equal(t, "photo.jpg", info.LinkTarget())
+		if assert.NotNil(t, info.Target()) {
+			assert.Equal(t, "photo.jpg", info.Target().Name())
+			assert.Equal(t, int64(5), info.Target().Size())
+			assert.False(t, info.Target().IsDir())
+		}
+	})
+
+	// Test case for a dangling symbolic link, which is reported instead of
+	// returning an error.
+	t.Run("dangling link", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "broken.jpg")
+		if err := os.Symlink(filepath.Join(dir, "missing.jpg"), path); err != nil {
+			t.Skip("Skipping symlink test because symlink could not be created")
+		}
+
+		info, err := NewFileInfoLstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, info.IsSymlink())
+		assert.True(t, info.IsDangling())
+		assert.Equal(t, filepath.Join(dir, "missing.jpg"), info.LinkTarget())
+		assert.Nil(t, info.Target())
+
+		_, err = NewFileInfo(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	// Test case for a path that does not exist.
+	t.Run("non-existing path", func(t *testing.T) {
+		_, err := NewFileInfoLstat(filepath.Join("testdata", "nonexistent.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+// TestAllocatedSize checks the allocated size and sparse flag of dense and
+// sparse files.
+func TestAllocatedSize(t *testing.T) {
+	dir := t.TempDir()
+
+	densePath := filepath.Join(dir, "dense.bin")
+	if err := os.WriteFile(densePath, make([]byte, 64*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dense, err := NewFileInfo(densePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, dense.IsSparse())
+
+	sparsePath := filepath.Join(dir, "sparse.bin")
+	f, err := os.Create(sparsePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("end"), 8*1024*1024); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sparse, err := NewFileInfo(sparsePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sparse.AllocatedSize() >= sparse.Size() {
+		t.Skip("Skipping sparse file test because the file system does not support sparse files")
+	}
+	assert.True(t, sparse.IsSparse())
+	assert.Less(t, sparse.AllocatedSize(), int64(1024*1024))
+}
@@ -4,6 +4,7 @@ package fs
 
 import (
 	"os"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -12,12 +13,13 @@ import (
 // It is part of a cross-platform file system utility package, where different
 // implementations are provided for different operating systems using build tags.
 
-// getCreationTime returns the creation time of a file.
-// It uses the `Ctim` field from `syscall.Stat_t`, which on Linux represents
-// the last status change time and is the closest equivalent to creation time.
-func getCreationTime(info os.FileInfo) time.Time {
+// getChangeTime returns the last status change time of a file and whether
+// it is available. It uses the `Ctim` field from `syscall.Stat_t`, which is
+// updated whenever the content or the metadata (permissions, owner, links)
+// of the file changes.
+func getChangeTime(info os.FileInfo, path string) (time.Time, bool) {
 	stat := info.Sys().(*syscall.Stat_t)
-	return time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec)
+	return time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec), true
 }
 
 // getLastAccessTime retrieves the last access time of a file.
@@ -36,12 +38,47 @@ func getLastWriteTime(info os.FileInfo) time.Time {
 	return time.Unix(stat.Mtim.Sec, stat.Mtim.Nsec)
 }
 
-// GetSize returns the size of a file or directory. On Unix-like systems,
-// the size reported by `os.FileInfo` is generally accurate for both files
-// and directories, so this function simply returns that value.
-// It returns the size of the file. For directories, it returns 0 as directory
-// size is not consistently defined on Unix-like systems.
-// For other file types, it returns the size from the os.FileInfo.
+// GetSize returns the size of a file or directory, as reported by
+// `os.FileInfo`. For directories, this is the size of the directory entry
+// itself, which is not consistently defined on Unix-like systems.
+// Use DirSize for a recursive directory size consistent across platforms.
 func GetSize(info os.FileInfo, path string) int64 {
 	return info.Size()
 }
+
+// isHidden reports whether a file is hidden. On Unix-like systems, a file is
+// hidden by convention when its name starts with a dot.
+func isHidden(info os.FileInfo) bool {
+	return strings.HasPrefix(info.Name(), ".")
+}
+
+// newSysInfo returns the portable system-dependent data of a file.
+// It is filled from the `syscall.Stat_t` structure obtained by os.Stat.
+func newSysInfo(info os.FileInfo, path string) *SysInfo {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return &SysInfo{Raw: info.Sys()}
+	}
+	return &SysInfo{
+		Dev:     uint64(stat.Dev),
+		Ino:     uint64(stat.Ino),
+		Nlink:   uint64(stat.Nlink),
+		Uid:     stat.Uid,
+		Gid:     stat.Gid,
+		Rdev:    uint64(stat.Rdev),
+		Blksize: int64(stat.Blksize),
+		Blocks:  int64(stat.Blocks),
+		Raw:     stat,
+	}
+}
+
+// getAllocatedSize returns the space allocated on disk for a file, in bytes.
+// It is computed from the `Blocks` field of `syscall.Stat_t`, which counts
+// 512-byte blocks regardless of the file system block size.
+func getAllocatedSize(info os.FileInfo, path string) int64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size()
+	}
+	return int64(stat.Blocks) * 512
+}
@@ -4,22 +4,85 @@ package fs
 
 import (
 	"os"
-	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
 // This file provides Windows-specific implementations for retrieving file timestamps.
 // It is part of a cross-platform file system utility package, where different
 // implementations are provided for different operating systems using build tags.
 
-// getCreationTime returns the creation time of a file.
+// getBirthTime returns the creation time of a file and whether it is available.
 // On Windows systems, the creation time is accessible via the syscall.Win32FileAttributeData
 // structure. This function extracts the creation time from the CreationTime field
-// and returns it as a time.Time object.
-func getCreationTime(info os.FileInfo) time.Time {
+// and returns it as a time.Time object. It is always available.
+func getBirthTime(info os.FileInfo, path string) (time.Time, bool) {
 	stat := info.Sys().(*syscall.Win32FileAttributeData)
-	return time.Unix(0, stat.CreationTime.Nanoseconds())
+	return time.Unix(0, stat.CreationTime.Nanoseconds()), true
+}
+
+// procGetCompressedFileSizeW is the GetCompressedFileSizeW function, which is
+// not exposed by the syscall and golang.org/x/sys/windows packages.
+var procGetCompressedFileSizeW = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetCompressedFileSizeW")
+
+// invalidFileSize is the INVALID_FILE_SIZE value returned by
+// GetCompressedFileSizeW on failure.
+const invalidFileSize = 0xFFFFFFFF
+
+// fileBasicInfo mirrors the FILE_BASIC_INFO structure returned by
+// GetFileInformationByHandleEx for the FileBasicInfo class.
+type fileBasicInfo struct {
+	CreationTime   int64
+	LastAccessTime int64
+	LastWriteTime  int64
+	ChangeTime     int64
+	FileAttributes uint32
+	_              uint32
+}
+
+// getChangeTime returns the last status change time of a file and whether it
+// is available. The change time is not part of syscall.Win32FileAttributeData,
+// so it is queried from an open handle with GetFileInformationByHandleEx.
+// File systems that do not record it, such as FAT, report zero.
+func getChangeTime(info os.FileInfo, path string) (time.Time, bool) {
+	h, err := openFileHandle(path, info)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer windows.CloseHandle(h)
+
+	var basic fileBasicInfo
+	err = windows.GetFileInformationByHandleEx(h, windows.FileBasicInfo,
+		(*byte)(unsafe.Pointer(&basic)), uint32(unsafe.Sizeof(basic)))
+	if err != nil || basic.ChangeTime == 0 {
+		return time.Time{}, false
+	}
+	ft := syscall.Filetime{
+		LowDateTime:  uint32(basic.ChangeTime),
+		HighDateTime: uint32(basic.ChangeTime >> 32),
+	}
+	return time.Unix(0, ft.Nanoseconds()), true
+}
+
+// openFileHandle opens a handle to query the attributes of the file or
+// directory at path. Symbolic links described by info are opened themselves
+// rather than their target.
+func openFileHandle(path string, info os.FileInfo) (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	flags := uint32(windows.FILE_FLAG_BACKUP_SEMANTICS)
+	if info.Mode()&os.ModeSymlink != 0 {
+		flags |= windows.FILE_FLAG_OPEN_REPARSE_POINT
+	}
+	return windows.CreateFile(name, windows.FILE_READ_ATTRIBUTES,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, flags, 0)
 }
 
 // getLastAccessTime retrieves the last access time of a file.
@@ -40,20 +103,78 @@ func getLastWriteTime(info os.FileInfo) time.Time {
 	return info.ModTime()
 }
 
-// GetSize returns the size of a file or directory. For files, it returns the
-// actual size. For directories, it calculates the total size of all files
-// within that directory (recursively), as the default directory size on
-// Windows is 0.
+// GetSize returns the size of a file or directory, as reported by
+// `os.FileInfo`. On Windows, the size of a directory is 0.
+// Use DirSize for a recursive directory size consistent across platforms.
 func GetSize(info os.FileInfo, path string) int64 {
-	if !info.IsDir() {
+	return info.Size()
+}
+
+// isHidden reports whether a file is hidden. On Windows, a file is hidden
+// when it carries the `FILE_ATTRIBUTE_HIDDEN` attribute. Names starting with
+// a dot are treated as hidden too, for consistency with other platforms.
+func isHidden(info os.FileInfo) bool {
+	if strings.HasPrefix(info.Name(), ".") {
+		return true
+	}
+	stat, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && stat.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
+
+// newSysInfo returns the portable system-dependent data of a file.
+// It is filled from the syscall.Win32FileAttributeData structure obtained by
+// os.Stat, which only provides the file attributes. The volume serial number,
+// the file index and the number of links are queried from an open handle with
+// GetFileInformationByHandle, when the file can be opened.
+func newSysInfo(info os.FileInfo, path string) *SysInfo {
+	stat, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return &SysInfo{Raw: info.Sys()}
+	}
+	sys := &SysInfo{
+		Attributes: stat.FileAttributes,
+		Raw:        stat,
+	}
+
+	h, err := openFileHandle(path, info)
+	if err != nil {
+		return sys
+	}
+	defer windows.CloseHandle(h)
+
+	var data windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &data); err == nil {
+		sys.Dev = uint64(data.VolumeSerialNumber)
+		sys.Ino = uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow)
+		sys.Nlink = uint64(data.NumberOfLinks)
+	}
+	return sys
+}
+
+// getAllocatedSize returns the space allocated on disk for a file, in bytes.
+// It uses GetCompressedFileSizeW, which reports the actual size on disk of
+// compressed and sparse files, and the file size otherwise. Directories and
+// files that cannot be queried report their file size.
+func getAllocatedSize(info os.FileInfo, path string) int64 {
+	if info.IsDir() {
+		return info.Size()
+	}
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
 		return info.Size()
 	}
-	var size int64
-	_ = filepath.Walk(path, func(_ string, f os.FileInfo, err error) error {
-		if err == nil && !f.IsDir() {
-			size += f.Size()
-		}
-		return nil // Ignore errors to calculate size of accessible files
-	})
-	return size
+	var high uint32
+	low, _, err := procGetCompressedFileSizeW.Call(uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&high)))
+	if uint32(low) == invalidFileSize && err != windows.ERROR_SUCCESS {
+		return info.Size()
+	}
+	return int64(high)<<32 | int64(uint32(low))
+}
+
+// isSparse reports whether a file is sparse, i.e. contains holes that are
+// not allocated on disk. On Windows, sparse files carry the
+// `FILE_ATTRIBUTE_SPARSE_FILE` attribute.
+func isSparse(info os.FileInfo, path string, allocated int64) bool {
+	stat, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && stat.FileAttributes&windows.FILE_ATTRIBUTE_SPARSE_FILE != 0
 }
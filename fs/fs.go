@@ -28,15 +28,15 @@ func IsDir(fileName string) bool {
 	return err == nil && isDir(info)
 }
 
-// isDir returns true if file exists and is a directory or symlink.
+// isDir returns true if file exists and is a directory.
+// A symbolic link is not a directory, even if it points to one; callers
+// describing links with os.Lstat must inspect the link target instead.
 func isDir(fileInfo os.FileInfo) bool {
 	if fileInfo == nil {
 		return false
 	}
 
-	m := fileInfo.Mode()
-
-	return m&os.ModeDir != 0 || m&os.ModeSymlink != 0
+	return fileInfo.Mode()&os.ModeDir != 0
 }
 
 // IsEmpty returns true if the destination is empty.
@@ -68,6 +68,29 @@ func isEmptyDir(dirName string) bool {
 // If the file path contains a tilde, it will be expanded to the home directory.
 // If the file path contains symbolic links, they will be resolved.
 func Resolve(filePath string) (string, error) {
+	absPath, err := absolute(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	// Resolve symbolic links
+	resolvedPath, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Check if the file exists
+	if _, err := os.Stat(resolvedPath); err != nil {
+		return "", err
+	}
+
+	return resolvedPath, nil
+}
+
+// absolute returns the absolute path to the file, without resolving
+// symbolic links. If the file path contains a tilde, it will be expanded to
+// the home directory.
+func absolute(filePath string) (string, error) {
 	if filePath == "" {
 		return "", os.ErrNotExist
 	}
@@ -81,21 +104,28 @@ func Resolve(filePath string) (string, error) {
 		filePath = filepath.Join(home, filePath[1:])
 	}
 
-	// Resolve symbolic links and get absolute path
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		return "", err
-	}
+	return filepath.Abs(filePath)
+}
 
-	resolvedPath, err := filepath.EvalSymlinks(absPath)
+// writeFileAtomic writes data to the file at path, replacing it atomically
+// through a temporary file renamed over it.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
 	if err != nil {
-		return "", err
+		return err
 	}
-
-	// Check if the file exists
-	if _, err := os.Stat(resolvedPath); err != nil {
-		return "", err
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
 	}
-
-	return resolvedPath, nil
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
 }
@@ -0,0 +1,99 @@
+package fs
+
+import "context"
+
+// This file provides utilities to detect hard links within a tree. Media
+// archives often use hard links to place one file in several albums, which
+// must be taken into account when summing sizes or removing files.
+
+// HardLinkGroup is a set of paths within a tree that refer to the same file
+// through hard links.
+type HardLinkGroup struct {
+	ID    FileID   // identity shared by all paths
+	Size  int64    // size of the file
+	Paths []string // absolute paths referring to the file, in walk order
+}
+
+// GroupHardLinks walks the tree rooted at root and returns the groups of
+// paths that refer to the same file. Only files with at least two paths
+// within the tree are reported. Groups are ordered by their first path.
+// Entries that cannot be read are skipped; an error is only returned if
+// root cannot be read or ctx is cancelled.
+func GroupHardLinks(ctx context.Context, root string, opts WalkOptions) ([]HardLinkGroup, error) {
+	groups := make(map[FileID]*HardLinkGroup)
+	var order []FileID
+
+	err := Walk(ctx, root, opts, skipEntryErrors(func(path string, info FileInfo) error {
+		// With FollowSymlinks, a link is reported with its target's identity
+		// although its own link count is 1, so the count cannot be used to
+		// filter candidates.
+		if info.IsDir() || info.FileID().IsZero() || (info.LinkCount() < 2 && !opts.FollowSymlinks) {
+			return nil
+		}
+		group, ok := groups[info.FileID()]
+		if !ok {
+			group = &HardLinkGroup{ID: info.FileID(), Size: info.Size()}
+			groups[info.FileID()] = group
+			order = append(order, info.FileID())
+		}
+		group.Paths = append(group.Paths, path)
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Groups are created in walk order, so they are ordered by their first path.
+	var result []HardLinkGroup
+	for _, id := range order {
+		if group := groups[id]; len(group.Paths) > 1 {
+			result = append(result, *group)
+		}
+	}
+	return result, nil
+}
+
+// UniqueSize walks the tree rooted at root and returns the total size of its
+// files, counting files reachable through several hard links only once.
+// Entries that cannot be read are skipped, as for GroupHardLinks.
+func UniqueSize(ctx context.Context, root string, opts WalkOptions) (int64, error) {
+	seen := make(map[FileID]bool)
+	var size int64
+
+	err := Walk(ctx, root, opts, skipEntryErrors(func(path string, info FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		if id := info.FileID(); !id.IsZero() {
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+		}
+		size += info.Size()
+		return nil
+	}))
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// skipEntryErrors returns a WalkFunc calling fn for the entries that could be
+// read. Errors reading the entries below the root are skipped, while errors
+// reading the root, which is always visited first, abort the walk.
+func skipEntryErrors(fn func(path string, info FileInfo) error) WalkFunc {
+	var root string
+	return func(path string, info FileInfo, err error) error {
+		if root == "" {
+			root = path
+		}
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		return fn(path, info)
+	}
+}
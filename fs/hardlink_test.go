@@ -0,0 +1,92 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardLinks(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	createTree(t, root, map[string]string{
+		"originals/a.jpg": "aaaa",
+		"originals/b.jpg": "bbbbbbbb",
+		"originals/c.jpg": "cc",
+	})
+	for _, link := range [][2]string{
+		{"originals/a.jpg", "albums/summer/a.jpg"},
+		{"originals/a.jpg", "albums/best/a.jpg"},
+		{"originals/b.jpg", "albums/best/b.jpg"},
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(link[1])), 0755))
+		if err := os.Link(filepath.Join(root, link[0]), filepath.Join(root, link[1])); err != nil {
+			t.Skip("Skipping hard link test because hard link could not be created")
+		}
+	}
+
+	t.Run("link count", func(t *testing.T) {
+		info, err := NewFileInfo(filepath.Join(root, "originals", "a.jpg"))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), info.LinkCount())
+
+		info, err = NewFileInfo(filepath.Join(root, "originals", "c.jpg"))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), info.LinkCount())
+	})
+
+	t.Run("group", func(t *testing.T) {
+		groups, err := GroupHardLinks(context.Background(), root, WalkOptions{})
+		require.NoError(t, err)
+		require.Len(t, groups, 2)
+
+		assert.Equal(t, int64(4), groups[0].Size)
+		assert.Equal(t, []string{
+			filepath.Join(root, "albums", "best", "a.jpg"),
+			filepath.Join(root, "albums", "summer", "a.jpg"),
+			filepath.Join(root, "originals", "a.jpg"),
+		}, groups[0].Paths)
+
+		assert.Equal(t, int64(8), groups[1].Size)
+		assert.Equal(t, []string{
+			filepath.Join(root, "albums", "best", "b.jpg"),
+			filepath.Join(root, "originals", "b.jpg"),
+		}, groups[1].Paths)
+	})
+
+	t.Run("group subtree", func(t *testing.T) {
+		groups, err := GroupHardLinks(context.Background(), filepath.Join(root, "albums"), WalkOptions{})
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		assert.Len(t, groups[0].Paths, 2)
+	})
+
+	t.Run("unique size", func(t *testing.T) {
+		size, err := UniqueSize(context.Background(), root, WalkOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4+8+2), size)
+	})
+
+	t.Run("unreadable entries", func(t *testing.T) {
+		if err := os.Symlink(filepath.Join(root, "missing.jpg"), filepath.Join(root, "broken.jpg")); err != nil {
+			t.Skip("Skipping unreadable entry test because symbolic link could not be created")
+		}
+		defer os.Remove(filepath.Join(root, "broken.jpg"))
+		opts := WalkOptions{FollowSymlinks: true}
+
+		groups, err := GroupHardLinks(context.Background(), root, opts)
+		require.NoError(t, err)
+		assert.Len(t, groups, 2)
+
+		size, err := UniqueSize(context.Background(), root, opts)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4+8+2), size)
+
+		_, err = UniqueSize(context.Background(), filepath.Join(root, "missing"), opts)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
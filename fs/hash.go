@@ -0,0 +1,314 @@
+package fs
+
+import (
+	"context"
+	"crypto/md5"  //nolint:gosec // MD5 is offered for compatibility, not security
+	"crypto/sha1" //nolint:gosec // SHA-1 is offered for compatibility, not security
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// This file provides streaming content hashing for FileInfo entries. It is
+// the foundation for deduplication and integrity checks: files are read once
+// in fixed-size buffers, feeding several hash algorithms at the same time,
+// and many files can be hashed concurrently.
+
+// HashAlgorithm identifies a hash algorithm supported by the package.
+type HashAlgorithm int
+
+const (
+	HashSHA256 HashAlgorithm = iota + 1 // SHA-256, the default algorithm
+	HashSHA1                            // SHA-1
+	HashMD5                             // MD5
+	HashCRC32                           // CRC-32 (IEEE polynomial)
+	HashXXH64                           // 64-bit xxHash, fast and non-cryptographic
+)
+
+// defaultBufferSize is the size of the buffers used to read files.
+const defaultBufferSize = 1 << 20
+
+// ErrUnknownHashAlgorithm is returned for unsupported hash algorithms.
+var ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+
+// String returns the lower-case name of the algorithm, e.g. "sha256".
+func (a HashAlgorithm) String() string {
+	switch a {
+	case HashSHA256:
+		return "sha256"
+	case HashSHA1:
+		return "sha1"
+	case HashMD5:
+		return "md5"
+	case HashCRC32:
+		return "crc32"
+	case HashXXH64:
+		return "xxh64"
+	default:
+		return fmt.Sprintf("HashAlgorithm(%d)", int(a))
+	}
+}
+
+// New returns a new hash.Hash computing the algorithm, or nil if the
+// algorithm is unknown.
+func (a HashAlgorithm) New() hash.Hash {
+	switch a {
+	case HashSHA256:
+		return sha256.New()
+	case HashSHA1:
+		return sha1.New() //nolint:gosec // see import
+	case HashMD5:
+		return md5.New() //nolint:gosec // see import
+	case HashCRC32:
+		return crc32.NewIEEE()
+	case HashXXH64:
+		return newXXH64()
+	default:
+		return nil
+	}
+}
+
+// ParseHashAlgorithm returns the algorithm with the given name, as returned
+// by HashAlgorithm.String. The comparison is case-insensitive and ignores
+// dashes, so that "SHA-256" is accepted.
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	name = strings.ReplaceAll(strings.ToLower(name), "-", "")
+	for a := HashSHA256; a <= HashXXH64; a++ {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownHashAlgorithm, name)
+}
+
+// Digest is the result of a hash computation.
+type Digest []byte
+
+// String returns the digest in lower-case hexadecimal.
+func (d Digest) String() string {
+	return hex.EncodeToString(d)
+}
+
+// Equal reports whether d and other are the same non-empty digest.
+func (d Digest) Equal(other Digest) bool {
+	return len(d) > 0 && string(d) == string(other)
+}
+
+// Digests maps hash algorithms to the digests computed for a file.
+type Digests map[HashAlgorithm]Digest
+
+// HashProgress reports the progress of hashing a file.
+type HashProgress struct {
+	Path  string // absolute path of the file being hashed
+	Read  int64  // number of bytes hashed so far
+	Total int64  // size of the file
+}
+
+// HashResult is the outcome of hashing one of the files passed to HashAll.
+type HashResult struct {
+	Info    FileInfo
+	Digests Digests
+	Err     error
+}
+
+// Hasher computes digests of file contents. The zero value is ready to use
+// and computes SHA-256 digests. A Hasher must not be copied after first use,
+// and is safe for concurrent use as long as its fields are not modified.
+type Hasher struct {
+	// Algorithms lists the algorithms to compute, in a single pass over the
+	// content. If empty, only SHA-256 is computed.
+	Algorithms []HashAlgorithm
+
+	// Workers is the number of files hashed concurrently by HashAll.
+	// A value of 0 or less uses runtime.NumCPU().
+	Workers int
+
+	// BufferSize is the size of the buffers used to read files.
+	// A value of 0 or less uses 1 MiB. Buffers are reused across files.
+	BufferSize int
+
+	// Progress, if not nil, is called after each buffer is hashed. It may be
+	// called concurrently by HashAll and must be safe for concurrent use.
+	Progress func(HashProgress)
+
+	// Cache, if not nil, provides the digests of files that did not change
+	// since they were last hashed, and stores the newly computed digests.
+	Cache *ChecksumCache
+
+	pool sync.Pool
+}
+
+// HashFile computes the digest of the file at path with a single algorithm.
+func HashFile(ctx context.Context, path string, algorithm HashAlgorithm) (Digest, error) {
+	h := Hasher{Algorithms: []HashAlgorithm{algorithm}}
+	digests, err := h.hashPath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return digests[algorithm], nil
+}
+
+// Hash computes the digests of the file described by info.
+func (h *Hasher) Hash(ctx context.Context, info FileInfo) (Digests, error) {
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s: cannot hash a directory", info.Abs())
+	}
+	if h.Cache == nil {
+		return h.hashPath(ctx, info.Abs())
+	}
+
+	if digests, ok := h.cached(info); ok {
+		return digests, nil
+	}
+	digests, err := h.hashPath(ctx, info.Abs())
+	if err != nil {
+		return nil, err
+	}
+	for a, digest := range digests {
+		_ = h.Cache.Put(info, a, digest) // caching is best effort
+	}
+	return digests, nil
+}
+
+// cached returns the digests of the file described by info from the cache,
+// if they are all available.
+func (h *Hasher) cached(info FileInfo) (Digests, bool) {
+	algorithms := h.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []HashAlgorithm{HashSHA256}
+	}
+
+	digests := make(Digests, len(algorithms))
+	for _, a := range algorithms {
+		digest, ok := h.Cache.Get(info, a)
+		if !ok {
+			return nil, false
+		}
+		digests[a] = digest
+	}
+	return digests, true
+}
+
+// HashReader computes the digests of the content read from r. The path is
+// only used to report progress; total is the expected size, or -1 if unknown.
+func (h *Hasher) HashReader(ctx context.Context, r io.Reader, path string, total int64) (Digests, error) {
+	algorithms := h.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []HashAlgorithm{HashSHA256}
+	}
+
+	hashes := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, a := range algorithms {
+		hashes[i] = a.New()
+		if hashes[i] == nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownHashAlgorithm, a)
+		}
+		writers[i] = hashes[i]
+	}
+	w := io.MultiWriter(writers...)
+
+	buf := h.getBuffer()
+	defer h.pool.Put(buf)
+
+	var read int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := r.Read(*buf)
+		if n > 0 {
+			_, _ = w.Write((*buf)[:n]) // hash writers never fail
+			read += int64(n)
+			if h.Progress != nil {
+				h.Progress(HashProgress{Path: path, Read: read, Total: total})
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	digests := make(Digests, len(algorithms))
+	for i, a := range algorithms {
+		digests[a] = hashes[i].Sum(nil)
+	}
+	return digests, nil
+}
+
+// HashAll computes the digests of the files described by infos concurrently.
+// The results are returned in the order of infos. Errors are reported per
+// file; if ctx is cancelled, the remaining files report the context error.
+func (h *Hasher) HashAll(ctx context.Context, infos []FileInfo) []HashResult {
+	results := make([]HashResult, len(infos))
+	forEach(len(infos), h.Workers, func(i int) {
+		digests, err := h.Hash(ctx, infos[i])
+		results[i] = HashResult{Info: infos[i], Digests: digests, Err: err}
+	})
+	return results
+}
+
+// forEach calls fn for each index in [0, n) using a bounded pool of workers,
+// and waits for all calls to return. A number of workers of 0 or less uses
+// runtime.NumCPU().
+func forEach(n, workers int, fn func(i int)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// hashPath computes the digests of the file at path.
+func (h *Hasher) hashPath(ctx context.Context, path string) (Digests, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	total := int64(-1)
+	if info, err := f.Stat(); err == nil {
+		total = info.Size()
+	}
+	return h.HashReader(ctx, f, path, total)
+}
+
+// getBuffer returns a read buffer from the pool, or a new one.
+func (h *Hasher) getBuffer() *[]byte {
+	size := h.BufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	if buf, ok := h.pool.Get().(*[]byte); ok && len(*buf) == size {
+		return buf
+	}
+	buf := make([]byte, size)
+	return &buf
+}
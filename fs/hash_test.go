@@ -0,0 +1,126 @@
+package fs
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashAlgorithms(t *testing.T) {
+	testCases := []struct {
+		algorithm HashAlgorithm
+		input     string
+		expected  string
+	}{
+		{HashSHA256, "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{HashSHA1, "abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{HashMD5, "abc", "900150983cd24fb0d6963f7d28e17f72"},
+		{HashCRC32, "abc", "352441c2"},
+		{HashXXH64, "", "ef46db3751d8e999"},
+		{HashXXH64, "a", "d24ec4f1a98c6e5b"},
+		{HashXXH64, "abc", "44bc2cf5ad770999"},
+		{HashXXH64, "The quick brown fox jumps over the lazy dog", "0b242d361fda71bc"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.algorithm.String()+"/"+tc.input, func(t *testing.T) {
+			h := Hasher{Algorithms: []HashAlgorithm{tc.algorithm}, BufferSize: 7}
+			digests, err := h.HashReader(context.Background(), strings.NewReader(tc.input), "", -1)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, digests[tc.algorithm].String())
+		})
+	}
+}
+
+func TestXXH64Streaming(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789abcdef", 20) + "tail")
+	whole := newXXH64()
+	_, _ = whole.Write(data)
+
+	// Writing the same data in chunks of every size must give the same hash.
+	for chunk := 1; chunk <= 40; chunk++ {
+		h := newXXH64()
+		for i := 0; i < len(data); i += chunk {
+			_, _ = h.Write(data[i:min(i+chunk, len(data))])
+		}
+		assert.Equal(t, whole.Sum64(), h.Sum64(), "chunk size %d", chunk)
+	}
+}
+
+func TestParseHashAlgorithm(t *testing.T) {
+	a, err := ParseHashAlgorithm("SHA-256")
+	require.NoError(t, err)
+	assert.Equal(t, HashSHA256, a)
+
+	a, err = ParseHashAlgorithm("xxh64")
+	require.NoError(t, err)
+	assert.Equal(t, HashXXH64, a)
+
+	_, err = ParseHashAlgorithm("blake3")
+	assert.ErrorIs(t, err, ErrUnknownHashAlgorithm)
+}
+
+func TestHasher(t *testing.T) {
+	dir := t.TempDir()
+	createTree(t, dir, map[string]string{
+		"a.jpg": "abc",
+		"b.jpg": strings.Repeat("x", 10000),
+		"c.jpg": "abc",
+	})
+
+	var infos []FileInfo
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg", "sub"} {
+		if name == "sub" {
+			createTree(t, dir, map[string]string{"sub/": ""})
+		}
+		info, err := NewFileInfo(filepath.Join(dir, name))
+		require.NoError(t, err)
+		infos = append(infos, info)
+	}
+
+	var (
+		mu       sync.Mutex
+		progress = make(map[string]int64)
+	)
+	h := Hasher{
+		Algorithms: []HashAlgorithm{HashSHA256, HashCRC32},
+		Workers:    2,
+		BufferSize: 4096,
+		Progress: func(p HashProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.LessOrEqual(t, p.Read, p.Total)
+			progress[p.Path] = p.Read
+		},
+	}
+
+	results := h.HashAll(context.Background(), infos)
+	require.Len(t, results, 4)
+	for _, r := range results[:3] {
+		require.NoError(t, r.Err)
+		assert.Len(t, r.Digests, 2)
+	}
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", results[0].Digests[HashSHA256].String())
+	assert.True(t, results[0].Digests[HashSHA256].Equal(results[2].Digests[HashSHA256]))
+	assert.False(t, results[0].Digests[HashSHA256].Equal(results[1].Digests[HashSHA256]))
+	assert.Error(t, results[3].Err)
+	assert.Equal(t, int64(10000), progress[infos[1].Abs()])
+
+	t.Run("hash file", func(t *testing.T) {
+		digest, err := HashFile(context.Background(), filepath.Join(dir, "a.jpg"), HashMD5)
+		require.NoError(t, err)
+		assert.Equal(t, "900150983cd24fb0d6963f7d28e17f72", digest.String())
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := h.Hash(ctx, infos[0])
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
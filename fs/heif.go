@@ -0,0 +1,416 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+)
+
+// This file provides a pure Go reader for the item structure of HEIF files,
+// the format of the HEIC photos taken by iPhones and of AVIF images. It
+// extracts the dimensions and rotation of the primary image, and the EXIF
+// metadata stored as a separate item. Only the meta box is read, never the
+// image data.
+
+// ErrNoHEIF is returned when a file is not a HEIF image.
+var ErrNoHEIF = errors.New("not a HEIF image")
+
+// heifMaxItemSize limits the size of the metadata items read.
+const heifMaxItemSize = 1 << 20
+
+// HEIF is the description of the primary image of a HEIF file. Fields that
+// are not recorded in the file are left zero.
+type HEIF struct {
+	Width    int   // width of the primary image, in pixels, before rotation
+	Height   int   // height of the primary image, in pixels, before rotation
+	Rotation int   // clockwise rotation of the image when displayed: 0, 90, 180 or 270 degrees
+	Exif     *Exif // EXIF metadata, nil if not recorded or unreadable
+}
+
+// heifExtent is a part of the data of an item.
+type heifExtent struct {
+	offset, length uint64
+}
+
+// heifItem is the location of the data of an item.
+type heifItem struct {
+	idat    bool // whether the extents are offsets in the idat box rather than the file
+	extents []heifExtent
+}
+
+// ReadHEIF reads the description of the HEIF image at path. It returns an
+// error wrapping ErrNoHEIF if the file is not a HEIF image.
+func ReadHEIF(path string) (*HEIF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h, err := DecodeHEIF(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return h, nil
+}
+
+// DecodeHEIF reads the description of the HEIF image read from r.
+func DecodeHEIF(r io.ReaderAt) (*HEIF, error) {
+	h, exif, err := decodeHEIF(r)
+	if err != nil {
+		return nil, err
+	}
+	if exif != nil {
+		h.Exif, _ = decodeTIFFExif(exif)
+	}
+	return h, nil
+}
+
+// decodeHEIFExif reads the EXIF metadata of the HEIF image read from r. The
+// dimensions of the primary image are used when the EXIF metadata does not
+// record them.
+func decodeHEIFExif(r io.ReaderAt) (*Exif, error) {
+	h, exif, err := decodeHEIF(r)
+	if err != nil {
+		return nil, err
+	}
+	if exif == nil {
+		return nil, ErrNoExif
+	}
+	x, err := decodeTIFFExif(exif)
+	if err != nil {
+		return nil, err
+	}
+	if x.Width == 0 || x.Height == 0 {
+		x.Width, x.Height = h.Width, h.Height
+	}
+	return x, nil
+}
+
+// decodeHEIF reads the description of the HEIF image read from r, and
+// returns the TIFF structure holding its EXIF metadata, nil if there is none.
+func decodeHEIF(r io.ReaderAt) (*HEIF, io.ReaderAt, error) {
+	head := make([]byte, sniffLen)
+	n, _ := r.ReadAt(head, 0)
+	switch DetectContentType(head[:n]).MIME {
+	case ContentTypeHEIC.MIME, ContentTypeHEIF.MIME, ContentTypeAVIF.MIME:
+	default:
+		return nil, nil, ErrNoHEIF
+	}
+
+	// The meta box follows the ftyp box, so the size of the file is not
+	// needed to find it.
+	meta, ok, err := findBox(r, 0, math.MaxInt64, "meta")
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok || meta.size < 4 {
+		return nil, nil, errInvalidBMFF
+	}
+	var last [1]byte
+	if _, err := r.ReadAt(last[:], meta.offset+meta.size-1); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errInvalidBMFF, err) // truncated
+	}
+	// The meta box starts with a version and flags.
+	boxes, err := readBoxes(r, meta.offset+4, meta.offset+meta.size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var primary uint32
+	var types map[uint32]string
+	var items map[uint32]heifItem
+	var properties []bmffBox
+	var associations map[uint32][]int
+	var idat bmffBox
+	for _, box := range boxes {
+		switch box.typ {
+		case "pitm":
+			primary, err = readPitm(r, box)
+		case "iinf":
+			types, err = readIinf(r, box)
+		case "iloc":
+			items, err = readIloc(r, box)
+		case "iprp":
+			properties, associations, err = readIprp(r, box)
+		case "idat":
+			idat = box
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	h := &HEIF{}
+	for _, index := range associations[primary] {
+		if index < 1 || index > len(properties) {
+			continue
+		}
+		property := properties[index-1]
+		switch property.typ {
+		case "ispe":
+			if data, err := readPayload(r, property, 64); err == nil && len(data) >= 12 {
+				h.Width = int(binary.BigEndian.Uint32(data[4:8]))
+				h.Height = int(binary.BigEndian.Uint32(data[8:12]))
+			}
+		case "irot":
+			if data, err := readPayload(r, property, 64); err == nil && len(data) >= 1 {
+				// The angle is stored in anti-clockwise steps of 90 degrees.
+				h.Rotation = (4 - int(data[0]&0x03)) % 4 * 90
+			}
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(types)) {
+		item, ok := items[id]
+		if types[id] != "Exif" || !ok {
+			continue
+		}
+		data, err := readHEIFItem(r, item, idat)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The EXIF item starts with the offset of the TIFF header, which
+		// may be preceded by the JPEG APP1 identifier.
+		if len(data) < 4 {
+			return nil, nil, errInvalidBMFF
+		}
+		offset := 4 + uint64(binary.BigEndian.Uint32(data[:4]))
+		if offset > uint64(len(data)) {
+			return nil, nil, errInvalidBMFF
+		}
+		return h, bytes.NewReader(data[offset:]), nil
+	}
+	return h, nil, nil
+}
+
+// readPitm returns the identifier of the primary item.
+func readPitm(r io.ReaderAt, pitm bmffBox) (uint32, error) {
+	data, err := readPayload(r, pitm, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case len(data) >= 6 && data[0] == 0:
+		return uint32(binary.BigEndian.Uint16(data[4:6])), nil
+	case len(data) >= 8:
+		return binary.BigEndian.Uint32(data[4:8]), nil
+	default:
+		return 0, errInvalidBMFF
+	}
+}
+
+// readIinf returns the types of the items, by identifier.
+func readIinf(r io.ReaderAt, iinf bmffBox) (map[uint32]string, error) {
+	var header [1]byte
+	if _, err := r.ReadAt(header[:], iinf.offset); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidBMFF, err)
+	}
+	countSize := int64(2)
+	if header[0] != 0 {
+		countSize = 4
+	}
+	entries, err := readBoxes(r, iinf.offset+4+countSize, iinf.offset+iinf.size)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[uint32]string)
+	for _, entry := range entries {
+		if entry.typ != "infe" {
+			continue
+		}
+		// Only the fixed prefix is read, since the name, content type or
+		// URI that follow it may be long.
+		data := make([]byte, min(entry.size, 14))
+		if _, err := r.ReadAt(data, entry.offset); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidBMFF, err)
+		}
+		// Versions 2 and 3 store the identifier, the protection index and
+		// the type; earlier versions do not store the type.
+		switch {
+		case len(data) >= 12 && data[0] == 2:
+			types[uint32(binary.BigEndian.Uint16(data[4:6]))] = string(data[8:12])
+		case len(data) >= 14 && data[0] == 3:
+			types[binary.BigEndian.Uint32(data[4:8])] = string(data[10:14])
+		}
+	}
+	return types, nil
+}
+
+// readIloc returns the locations of the items, by identifier.
+func readIloc(r io.ReaderAt, iloc bmffBox) (map[uint32]heifItem, error) {
+	data, err := readPayload(r, iloc, heifMaxItemSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, errInvalidBMFF
+	}
+	version := data[0]
+	offsetSize, lengthSize := int(data[4]>>4), int(data[4]&0x0F)
+	baseOffsetSize, indexSize := int(data[5]>>4), int(data[5]&0x0F)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	pos := 6
+	read := func(size int) (uint64, bool) {
+		if (size != 0 && size != 2 && size != 4 && size != 8) || pos+size > len(data) {
+			return 0, false
+		}
+		var v uint64
+		for _, b := range data[pos : pos+size] {
+			v = v<<8 | uint64(b)
+		}
+		pos += size
+		return v, true
+	}
+
+	idSize := 2
+	if version >= 2 {
+		idSize = 4
+	}
+	count, ok := read(idSize)
+	if !ok {
+		return nil, errInvalidBMFF
+	}
+
+	items := make(map[uint32]heifItem)
+	for range count {
+		id, ok := read(idSize)
+		if !ok {
+			return nil, errInvalidBMFF
+		}
+		var item heifItem
+		if version >= 1 {
+			method, ok := read(2)
+			if !ok {
+				return nil, errInvalidBMFF
+			}
+			item.idat = method&0x0F == 1
+		}
+		_, okReference := read(2)
+		baseOffset, okBase := read(baseOffsetSize)
+		extentCount, okCount := read(2)
+		if !okReference || !okBase || !okCount {
+			return nil, errInvalidBMFF
+		}
+		for range extentCount {
+			_, okIndex := read(indexSize)
+			offset, okOffset := read(offsetSize)
+			length, okLength := read(lengthSize)
+			if !okIndex || !okOffset || !okLength {
+				return nil, errInvalidBMFF
+			}
+			item.extents = append(item.extents, heifExtent{offset: baseOffset + offset, length: length})
+		}
+		items[uint32(id)] = item
+	}
+	return items, nil
+}
+
+// readIprp returns the properties stored in the item properties box, in
+// order, and the 1-based indexes of the properties associated with each
+// item, by identifier.
+func readIprp(r io.ReaderAt, iprp bmffBox) ([]bmffBox, map[uint32][]int, error) {
+	boxes, err := readBoxes(r, iprp.offset, iprp.offset+iprp.size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var properties []bmffBox
+	associations := make(map[uint32][]int)
+	for _, box := range boxes {
+		switch box.typ {
+		case "ipco":
+			if properties, err = readBoxes(r, box.offset, box.offset+box.size); err != nil {
+				return nil, nil, err
+			}
+		case "ipma":
+			data, err := readPayload(r, box, heifMaxItemSize)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := parseIpma(data, associations); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return properties, associations, nil
+}
+
+// parseIpma adds the property associations of the payload of an ipma box
+// to associations.
+func parseIpma(data []byte, associations map[uint32][]int) error {
+	if len(data) < 8 {
+		return errInvalidBMFF
+	}
+	version, flags := data[0], data[3]
+	count := binary.BigEndian.Uint32(data[4:8])
+	pos := 8
+	for range count {
+		var id uint32
+		if version < 1 {
+			if pos+3 > len(data) {
+				return errInvalidBMFF
+			}
+			id = uint32(binary.BigEndian.Uint16(data[pos:]))
+			pos += 2
+		} else {
+			if pos+5 > len(data) {
+				return errInvalidBMFF
+			}
+			id = binary.BigEndian.Uint32(data[pos:])
+			pos += 4
+		}
+		n := int(data[pos])
+		pos++
+		for range n {
+			// The highest bit flags essential properties.
+			if flags&1 != 0 {
+				if pos+2 > len(data) {
+					return errInvalidBMFF
+				}
+				associations[id] = append(associations[id], int(binary.BigEndian.Uint16(data[pos:])&0x7FFF))
+				pos += 2
+			} else {
+				if pos+1 > len(data) {
+					return errInvalidBMFF
+				}
+				associations[id] = append(associations[id], int(data[pos]&0x7F))
+				pos++
+			}
+		}
+	}
+	return nil
+}
+
+// readHEIFItem reads the data of an item, concatenating its extents.
+func readHEIFItem(r io.ReaderAt, item heifItem, idat bmffBox) ([]byte, error) {
+	var data []byte
+	for _, extent := range item.extents {
+		offset := extent.offset
+		if item.idat {
+			if extent.offset+extent.length > uint64(idat.size) {
+				return nil, errInvalidBMFF
+			}
+			offset += uint64(idat.offset)
+		}
+		if extent.length > heifMaxItemSize || uint64(len(data))+extent.length > heifMaxItemSize {
+			return nil, errInvalidBMFF
+		}
+		chunk := make([]byte, extent.length)
+		if _, err := r.ReadAt(chunk, int64(offset)); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidBMFF, err)
+		}
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
@@ -0,0 +1,174 @@
+package fs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildHEIC builds a HEIC image of the given dimensions and anti-clockwise
+// rotation steps, with the TIFF structure holding its EXIF metadata if not
+// nil. The EXIF item is stored in the idat box, or after the meta box.
+func buildHEIC(tiff []byte, width, height uint32, rotation byte, inIdat bool) []byte {
+	exif := append([]byte{0, 0, 0, 6}, "Exif\x00\x00"...)
+	exif = append(exif, tiff...)
+
+	infe := func(id uint16, typ string, fields ...string) []byte {
+		b := []byte{2, 0, 0, 0}
+		b = binary.BigEndian.AppendUint16(b, id)
+		b = append(b, 0, 0)
+		b = append(append(b, typ...), 0) // empty name
+		for _, field := range fields {
+			b = append(append(b, field...), 0)
+		}
+		return buildBox("infe", b)
+	}
+	// The XMP item has a long content type.
+	iinf := []byte{0, 0, 0, 0, 0, 2}
+	iinf = append(iinf, infe(1, "hvc1")...)
+	iinf = append(iinf, infe(3, "mime", "application/rdf+xml; "+strings.Repeat("x", 512))...)
+	if tiff != nil {
+		iinf[5] = 3
+		iinf = append(iinf, infe(2, "Exif")...)
+	}
+
+	ispe := binary.BigEndian.AppendUint32(make([]byte, 4), width)
+	ispe = binary.BigEndian.AppendUint32(ispe, height)
+	ipma := []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 2, 0x81, 0x02}
+	iprp := buildBox("iprp",
+		buildBox("ipco", buildBox("ispe", ispe), buildBox("irot", []byte{rotation})),
+		buildBox("ipma", ipma))
+
+	meta := func(exifOffset uint32) []byte {
+		iloc := []byte{1, 0, 0, 0, 0x44, 0x00, 0, 1}
+		method := byte(0)
+		if inIdat {
+			method = 1
+		}
+		iloc = append(iloc, 0, 2, 0, method, 0, 0, 0, 1)
+		iloc = binary.BigEndian.AppendUint32(iloc, exifOffset)
+		iloc = binary.BigEndian.AppendUint32(iloc, uint32(len(exif)))
+
+		boxes := [][]byte{
+			{0, 0, 0, 0},
+			buildBox("hdlr", append(make([]byte, 8), "pict\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...)),
+			buildBox("pitm", []byte{0, 0, 0, 0, 0, 1}),
+			buildBox("iinf", iinf),
+			buildBox("iloc", iloc),
+			iprp,
+		}
+		if inIdat {
+			boxes = append(boxes, buildBox("idat", exif))
+		}
+		return buildBox("meta", boxes...)
+	}
+
+	b := ftyp("heic", "mif1", "heic")
+	if inIdat {
+		return append(append(b, meta(0)...), buildBox("mdat", make([]byte, 32))...)
+	}
+	offset := len(b) + len(meta(0)) + 8
+	return append(append(b, meta(uint32(offset))...), buildBox("mdat", exif)...)
+}
+
+func TestReadHEIF(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name     string
+		rotation byte
+		inIdat   bool
+		expected int
+	}{
+		{"mdat.heic", 0, false, 0},
+		{"idat.heic", 1, true, 270},
+		{"upside-down.heic", 2, false, 180},
+		{"rotated.heic", 3, true, 90},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			require.NoError(t, os.WriteFile(path, buildHEIC(testExifTIFF(), 4032, 3024, tc.rotation, tc.inIdat), 0644))
+
+			h, err := ReadHEIF(path)
+			require.NoError(t, err)
+			assert.Equal(t, 4032, h.Width)
+			assert.Equal(t, 3024, h.Height)
+			assert.Equal(t, tc.expected, h.Rotation)
+			require.NotNil(t, h.Exif)
+			assert.Equal(t, "Pixel 7", h.Exif.Model)
+
+			x, err := ReadExif(path)
+			require.NoError(t, err)
+			assert.Equal(t, "Google", x.Make)
+			require.NotNil(t, x.GPS)
+		})
+	}
+}
+
+func TestReadHEIFWithoutExif(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.heic")
+	require.NoError(t, os.WriteFile(path, buildHEIC(nil, 640, 480, 0, true), 0644))
+
+	h, err := ReadHEIF(path)
+	require.NoError(t, err)
+	assert.Equal(t, 640, h.Width)
+	assert.Equal(t, 480, h.Height)
+	assert.Nil(t, h.Exif)
+
+	_, err = ReadExif(path)
+	assert.ErrorIs(t, err, ErrNoExif)
+
+	// The dimensions of the image are used when EXIF does not record them.
+	tiff := buildTIFF(binary.LittleEndian, []tiffField{{exifTagMake, "Apple"}})
+	require.NoError(t, os.WriteFile(path, buildHEIC(tiff, 640, 480, 0, false), 0644))
+	x, err := ReadExif(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Apple", x.Make)
+	assert.Equal(t, 640, x.Width)
+	assert.Equal(t, 480, x.Height)
+}
+
+func TestReadHEIFErrors(t *testing.T) {
+	dir := t.TempDir()
+	createTree(t, dir, map[string]string{
+		"photo.jpg":      string(buildJPEG(nil, 16, 12)),
+		"no-meta.heic":   string(append(ftyp("heic", "mif1", "heic"), buildBox("mdat", make([]byte, 16))...)),
+		"truncated.avif": string(append(ftyp("avif", "mif1", "avif"), "\x00\x00\x10\x00meta"...)),
+	})
+
+	_, err := ReadHEIF(filepath.Join(dir, "photo.jpg"))
+	assert.ErrorIs(t, err, ErrNoHEIF)
+
+	_, err = ReadHEIF(filepath.Join(dir, "no-meta.heic"))
+	assert.ErrorIs(t, err, errInvalidBMFF)
+
+	_, err = ReadHEIF(filepath.Join(dir, "truncated.avif"))
+	assert.ErrorIs(t, err, errInvalidBMFF)
+}
+
+func TestFileInfoHEIF(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "IMG_0001.HEIC")
+	require.NoError(t, os.WriteFile(path, buildHEIC(nil, 4032, 3024, 1, true), 0644))
+
+	info, err := NewFileInfo(path)
+	require.NoError(t, err)
+	h, err := info.HEIF()
+	require.NoError(t, err)
+	assert.Equal(t, 4032, h.Width)
+	assert.Equal(t, 270, h.Rotation)
+
+	cached, err := info.HEIF()
+	require.NoError(t, err)
+	assert.Same(t, h, cached)
+
+	dirInfo, err := NewFileInfo(dir)
+	require.NoError(t, err)
+	_, err = dirInfo.HEIF()
+	assert.ErrorIs(t, err, ErrNoHEIF)
+}
@@ -0,0 +1,172 @@
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// This file provides a minimal reader for ID3v2 tags, the metadata at the
+// start of MP3 files, extracting the recording date. Only the headers of the
+// frames are read, skipping cover pictures and other large payloads.
+
+// errNoID3 is returned when data does not start with an ID3v2 tag.
+var errNoID3 = errors.New("no ID3v2 tag")
+
+// id3MaxTextSize limits the size of the text frames read.
+const id3MaxTextSize = 256
+
+// id3Precision is the precision of the recording date of an ID3v2 tag.
+type id3Precision int
+
+const (
+	id3PrecisionYear id3Precision = iota // only the year, and maybe the month, is known
+	id3PrecisionDay                      // the day is known, but not the time of the day
+	id3PrecisionTime                     // the time of the day is known
+)
+
+// readID3Date reads the recording date of the ID3v2 tag at the start of r,
+// in the local time zone, and returns its precision. A date whose day is not
+// known is set to the first day of its year or month.
+func readID3Date(r io.ReaderAt) (time.Time, id3Precision, error) {
+	var header [10]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil || string(header[:3]) != "ID3" {
+		return time.Time{}, 0, errNoID3
+	}
+	version, flags := header[3], header[5]
+	if version < 2 || version > 4 {
+		return time.Time{}, 0, errNoID3
+	}
+	end := 10 + int64(syncsafe(header[6:10]))
+
+	offset := int64(10)
+	if flags&0x40 != 0 && version >= 3 { // extended header
+		var size [4]byte
+		if _, err := r.ReadAt(size[:], offset); err != nil {
+			return time.Time{}, 0, err
+		}
+		if version == 4 {
+			offset += int64(syncsafe(size[:]))
+		} else {
+			offset += 4 + int64(binary.BigEndian.Uint32(size[:]))
+		}
+	}
+
+	idLen, headerLen := 4, int64(10)
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+	frames := make(map[string]string)
+	frame := make([]byte, headerLen)
+	for offset+headerLen <= end {
+		if _, err := r.ReadAt(frame, offset); err != nil {
+			break
+		}
+		if frame[0] == 0 { // padding
+			break
+		}
+		id := string(frame[:idLen])
+		var size int64
+		switch version {
+		case 2:
+			size = int64(frame[3])<<16 | int64(frame[4])<<8 | int64(frame[5])
+		case 3:
+			size = int64(binary.BigEndian.Uint32(frame[4:8]))
+		case 4:
+			size = int64(syncsafe(frame[4:8]))
+		}
+
+		switch id {
+		case "TDRC", "TYER", "TDAT", "TIME", "TYE", "TDA", "TIM":
+			if size <= id3MaxTextSize {
+				data := make([]byte, size)
+				if _, err := r.ReadAt(data, offset+headerLen); err == nil {
+					frames[id] = decodeID3Text(data)
+				}
+			}
+		}
+		offset += headerLen + size
+	}
+
+	if value, ok := frames["TDRC"]; ok { // ID3v2.4 timestamp
+		for _, layout := range []struct {
+			layout    string
+			precision id3Precision
+		}{
+			{"2006-01-02T15:04:05", id3PrecisionTime},
+			{"2006-01-02T15:04", id3PrecisionTime},
+			{"2006-01-02T15", id3PrecisionTime},
+			{"2006-01-02", id3PrecisionDay},
+			{"2006-01", id3PrecisionYear},
+			{"2006", id3PrecisionYear},
+		} {
+			if t, err := time.ParseInLocation(layout.layout, value, time.Local); err == nil {
+				return t, layout.precision, nil
+			}
+		}
+		return time.Time{}, 0, errors.New("no ID3 recording date")
+	}
+
+	// ID3v2.3 and ID3v2.2 store the year, the day and the time separately;
+	// the day and the time are often missing.
+	year, day, clock := frames["TYER"], frames["TDAT"], frames["TIME"]
+	if version == 2 {
+		year, day, clock = frames["TYE"], frames["TDA"], frames["TIM"]
+	}
+	t, err := time.ParseInLocation("2006 0201", year+" "+day, time.Local)
+	if err != nil {
+		if t, err := time.ParseInLocation("2006", year, time.Local); err == nil {
+			return t, id3PrecisionYear, nil
+		}
+		return time.Time{}, 0, errors.New("no ID3 recording date")
+	}
+	if c, err := time.Parse("1504", clock); err == nil {
+		return t.Add(time.Duration(c.Hour())*time.Hour + time.Duration(c.Minute())*time.Minute), id3PrecisionTime, nil
+	}
+	return t, id3PrecisionDay, nil
+}
+
+// syncsafe decodes a 28-bit integer stored in 4 bytes of 7 bits each.
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// decodeID3Text decodes the payload of a text frame, made of an encoding
+// byte followed by the text.
+func decodeID3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	encoding, text := data[0], data[1:]
+
+	var s string
+	switch encoding {
+	case 1, 2: // UTF-16 with byte order mark, UTF-16BE
+		var order binary.ByteOrder = binary.BigEndian
+		if len(text) >= 2 && text[0] == 0xFF && text[1] == 0xFE {
+			order, text = binary.LittleEndian, text[2:]
+		} else if len(text) >= 2 && text[0] == 0xFE && text[1] == 0xFF {
+			text = text[2:]
+		}
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			units[i] = order.Uint16(text[i*2:])
+		}
+		s = string(utf16.Decode(units))
+	case 3: // UTF-8
+		s = string(text)
+	default: // ISO-8859-1
+		runes := make([]rune, len(text))
+		for i, b := range text {
+			runes[i] = rune(b)
+		}
+		s = string(runes)
+	}
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
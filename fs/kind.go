@@ -0,0 +1,126 @@
+package fs
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// This file defines the media kinds used to classify files, and a registry
+// mapping file extensions to kinds. The registry is shared by the whole
+// package and can be extended at startup with custom extensions.
+
+// Kind is a coarse classification of the content of a file.
+type Kind int
+
+const (
+	KindOther    Kind = iota // unknown or unclassified content
+	KindImage                // still images, including camera RAW formats
+	KindVideo                // video files
+	KindAudio                // audio files
+	KindSidecar              // metadata files accompanying media files (XMP, AAE, THM, ...)
+	KindDocument             // text, office and PDF documents
+	KindArchive              // compressed or uncompressed archives
+)
+
+// String returns the lower-case name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindImage:
+		return "image"
+	case KindVideo:
+		return "video"
+	case KindAudio:
+		return "audio"
+	case KindSidecar:
+		return "sidecar"
+	case KindDocument:
+		return "document"
+	case KindArchive:
+		return "archive"
+	default:
+		return "other"
+	}
+}
+
+// kindRegistry maps normalized extensions to kinds.
+var kindRegistry = struct {
+	sync.RWMutex
+	kinds map[string]Kind
+}{kinds: defaultKinds()}
+
+// defaultKinds returns the extensions registered by default.
+func defaultKinds() map[string]Kind {
+	kinds := make(map[string]Kind)
+	add := func(kind Kind, exts ...string) {
+		for _, ext := range exts {
+			kinds[normalizeExt(ext)] = kind
+		}
+	}
+	add(KindImage,
+		".jpg", ".jpeg", ".jpe", ".jfif", ".png", ".gif", ".webp", ".heic", ".heif", ".hif", ".avif",
+		".bmp", ".tif", ".tiff", ".psd", ".svg", ".jxl",
+		".dng", ".cr2", ".cr3", ".crw", ".nef", ".nrw", ".arw", ".srf", ".sr2", ".orf", ".rw2", ".rwl",
+		".raf", ".pef", ".srw", ".3fr", ".erf", ".kdc", ".mef", ".mos", ".iiq", ".x3f")
+	add(KindVideo,
+		".mp4", ".m4v", ".mov", ".qt", ".avi", ".mkv", ".mk3d", ".webm", ".ogv", ".3gp", ".3g2",
+		".mts", ".m2ts", ".ts", ".mpg", ".mpeg", ".vob", ".wmv", ".flv", ".insv", ".360")
+	add(KindAudio,
+		".mp3", ".m4a", ".m4b", ".aac", ".flac", ".ogg", ".oga", ".opus", ".wav", ".wave",
+		".aif", ".aiff", ".aifc", ".wma", ".ape", ".alac", ".mka", ".mid", ".midi")
+	add(KindSidecar,
+		".xmp", ".aae", ".thm", ".lrv", ".pp3", ".dop", ".on1", ".xml", ".srt", ".vtt", ".lrc", ".cue")
+	add(KindDocument,
+		".pdf", ".txt", ".md", ".rtf", ".doc", ".docx", ".odt", ".xls", ".xlsx", ".ods",
+		".ppt", ".pptx", ".odp", ".csv", ".html", ".htm", ".epub")
+	add(KindArchive,
+		".zip", ".tar", ".gz", ".tgz", ".bz2", ".tbz2", ".xz", ".txz", ".zst", ".7z", ".rar", ".iso", ".dmg")
+	return kinds
+}
+
+// RegisterKind associates the given extensions with kind, replacing any
+// previous association. Extensions are case-insensitive and the leading dot
+// is optional. It is safe for concurrent use, but is typically called at
+// startup to register custom extensions.
+func RegisterKind(kind Kind, exts ...string) {
+	kindRegistry.Lock()
+	defer kindRegistry.Unlock()
+	for _, ext := range exts {
+		kindRegistry.kinds[normalizeExt(ext)] = kind
+	}
+}
+
+// KindOf returns the kind registered for the extension ext, or KindOther if
+// the extension is not registered. The lookup is case-insensitive and the
+// leading dot is optional.
+func KindOf(ext string) Kind {
+	if ext == "" {
+		return KindOther
+	}
+	kindRegistry.RLock()
+	defer kindRegistry.RUnlock()
+	return kindRegistry.kinds[normalizeExt(ext)]
+}
+
+// ExtsOf returns the extensions registered for kind, sorted alphabetically.
+func ExtsOf(kind Kind) []string {
+	kindRegistry.RLock()
+	defer kindRegistry.RUnlock()
+	var exts []string
+	for ext, k := range kindRegistry.kinds {
+		if k == kind {
+			exts = append(exts, ext)
+		}
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// normalizeExt returns ext in lower case with a leading dot.
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,83 @@
+package fs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindOf(t *testing.T) {
+	testCases := []struct {
+		ext      string
+		expected Kind
+	}{
+		{".jpg", KindImage},
+		{".JPG", KindImage},
+		{"jpeg", KindImage},
+		{".JPeG", KindImage},
+		{".nef", KindImage},
+		{".MOV", KindVideo},
+		{".flac", KindAudio},
+		{".xmp", KindSidecar},
+		{".AAE", KindSidecar},
+		{".pdf", KindDocument},
+		{".zip", KindArchive},
+		{".unknown", KindOther},
+		{"", KindOther},
+		{".", KindOther},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ext, func(t *testing.T) {
+			assert.Equal(t, tc.expected, KindOf(tc.ext))
+		})
+	}
+}
+
+func TestRegisterKind(t *testing.T) {
+	t.Cleanup(func() {
+		kindRegistry.Lock()
+		defer kindRegistry.Unlock()
+		delete(kindRegistry.kinds, ".custom")
+		delete(kindRegistry.kinds, ".braw")
+	})
+
+	RegisterKind(KindImage, ".CUSTOM")
+	RegisterKind(KindVideo, "braw")
+
+	assert.Equal(t, KindImage, KindOf(".custom"))
+	assert.Equal(t, KindVideo, KindOf(".BRAW"))
+	assert.Contains(t, ExtsOf(KindVideo), ".braw")
+	assert.NotContains(t, ExtsOf(KindImage), ".braw")
+}
+
+func TestKindString(t *testing.T) {
+	assert.Equal(t, "image", KindImage.String())
+	assert.Equal(t, "sidecar", KindSidecar.String())
+	assert.Equal(t, "other", Kind(-1).String())
+}
+
+func TestFileInfoKind(t *testing.T) {
+	dir := t.TempDir()
+	createTree(t, dir, map[string]string{
+		"IMG_0001.JPG": "",
+		"IMG_0001.AAE": "",
+		"clip.mp4":     "",
+		"album.jpg/":   "",
+		"README":       "",
+	})
+
+	for name, expected := range map[string]Kind{
+		"IMG_0001.JPG": KindImage,
+		"IMG_0001.AAE": KindSidecar,
+		"clip.mp4":     KindVideo,
+		"album.jpg":    KindOther,
+		"README":       KindOther,
+	} {
+		info, err := NewFileInfo(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, expected, info.Kind(), name)
+	}
+}
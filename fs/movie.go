@@ -0,0 +1,309 @@
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file provides a pure Go reader for the metadata of QuickTime and MP4
+// movies, the ISO base media files recorded by phones and cameras: duration,
+// creation time, resolution, codec, rotation and location. Only the movie
+// box is read, never the media data, so no external tool is needed.
+
+// ErrNoMovie is returned when a file is not a QuickTime or MP4 movie, or
+// lacks the movie box describing its tracks.
+var ErrNoMovie = errors.New("no movie metadata")
+
+// quickTimeEpochOffset is the number of seconds between the QuickTime epoch,
+// 1904-01-01, and the Unix epoch.
+const quickTimeEpochOffset = 2082844800
+
+// movieMaxBoxSize limits the size of the boxes read, protecting the reader
+// against corrupted or malicious files.
+const movieMaxBoxSize = 1 << 16
+
+// QuickTime metadata keys.
+const (
+	movieKeyLocation     = "com.apple.quicktime.location.ISO6709"
+	movieKeyMake         = "com.apple.quicktime.make"
+	movieKeyModel        = "com.apple.quicktime.model"
+	movieKeyCreationDate = "com.apple.quicktime.creationdate"
+)
+
+// Movie is the metadata of a QuickTime or MP4 movie. Fields that are not
+// recorded in the file are left zero.
+type Movie struct {
+	Duration     time.Duration // duration of the movie
+	CreationTime time.Time     // when the movie was recorded
+
+	Width    int    // width of the first video track, in pixels, before rotation
+	Height   int    // height of the first video track, in pixels, before rotation
+	Codec    string // four-character code of the video codec, e.g. "avc1" or "hvc1"
+	Rotation int    // clockwise rotation of the video when displayed: 0, 90, 180 or 270 degrees
+
+	Make  string // manufacturer of the recording device
+	Model string // model of the recording device
+
+	GPS *GPSInfo // location where the movie was recorded, nil if not recorded
+}
+
+// ReadMovie reads the metadata of the QuickTime or MP4 movie at path. It
+// returns an error wrapping ErrNoMovie if the file is not a movie.
+func ReadMovie(path string) (*Movie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	m, err := DecodeMovie(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// DecodeMovie reads the metadata of the QuickTime or MP4 movie of the given
+// size read from r.
+func DecodeMovie(r io.ReaderAt, size int64) (*Movie, error) {
+	head := make([]byte, sniffLen)
+	n, _ := r.ReadAt(head, 0)
+	switch DetectContentType(head[:n]).MIME {
+	case ContentTypeMP4.MIME, ContentTypeM4V.MIME, ContentTypeM4A.MIME, ContentType3GP.MIME, ContentTypeQuickTime.MIME:
+	default:
+		return nil, ErrNoMovie
+	}
+
+	moov, ok, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoMovie
+	}
+	boxes, err := readBoxes(r, moov.offset, moov.offset+moov.size)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Movie{}
+	for _, box := range boxes {
+		switch box.typ {
+		case "mvhd":
+			if err := m.decodeMvhd(r, box); err != nil {
+				return nil, err
+			}
+		case "trak":
+			if m.Codec == "" {
+				m.decodeTrak(r, box)
+			}
+		case "udta":
+			if xyz, ok, _ := findBox(r, box.offset, box.offset+box.size, "\xA9xyz"); ok {
+				if data, err := readPayload(r, xyz, movieMaxBoxSize); err == nil && len(data) > 4 {
+					m.GPS = parseISO6709(string(data[4:])) // after the size and language of the string
+				}
+			}
+		case "meta":
+			m.decodeMeta(r, box)
+		}
+	}
+	return m, nil
+}
+
+// decodeMvhd sets the fields stored in the movie header box.
+func (m *Movie) decodeMvhd(r io.ReaderAt, box bmffBox) error {
+	data, err := readPayload(r, box, movieMaxBoxSize)
+	if err != nil {
+		return err
+	}
+
+	var created, timescale, duration uint64
+	switch {
+	case len(data) >= 20 && data[0] == 0:
+		created = uint64(binary.BigEndian.Uint32(data[4:8]))
+		timescale = uint64(binary.BigEndian.Uint32(data[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+	case len(data) >= 32 && data[0] == 1:
+		created = binary.BigEndian.Uint64(data[4:12])
+		timescale = uint64(binary.BigEndian.Uint32(data[20:24]))
+		duration = binary.BigEndian.Uint64(data[24:32])
+	default:
+		return errInvalidBMFF
+	}
+
+	// Devices without a clock record a zero creation time.
+	if created != 0 && created < math.MaxInt64/2 {
+		m.CreationTime = time.Unix(int64(created)-quickTimeEpochOffset, 0).UTC()
+	}
+	if timescale != 0 && duration != math.MaxUint32 && duration != math.MaxUint64 {
+		m.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+	}
+	return nil
+}
+
+// decodeTrak sets the fields describing the track, if it is a video track.
+func (m *Movie) decodeTrak(r io.ReaderAt, trak bmffBox) {
+	end := trak.offset + trak.size
+	hdlr, ok, err := findBox(r, trak.offset, end, "mdia", "hdlr")
+	if err != nil || !ok {
+		return
+	}
+	data, err := readPayload(r, hdlr, movieMaxBoxSize)
+	if err != nil || len(data) < 12 || string(data[8:12]) != "vide" {
+		return
+	}
+
+	if stsd, ok, err := findBox(r, trak.offset, end, "mdia", "minf", "stbl", "stsd"); err == nil && ok {
+		// Version and flags, entry count, then the size and type of the
+		// first sample entry.
+		var entry [16]byte
+		if stsd.size >= 16 {
+			if _, err := r.ReadAt(entry[:], stsd.offset); err == nil {
+				m.Codec = string(entry[12:16])
+			}
+		}
+	}
+
+	tkhd, ok, err := findBox(r, trak.offset, end, "tkhd")
+	if err != nil || !ok {
+		return
+	}
+	data, err = readPayload(r, tkhd, movieMaxBoxSize)
+	if err != nil {
+		return
+	}
+	matrix := 40
+	if len(data) > 0 && data[0] == 1 {
+		matrix = 52
+	}
+	if len(data) < matrix+44 {
+		return
+	}
+	// The width and height follow the transformation matrix, as 16.16
+	// fixed-point numbers.
+	m.Width = int(binary.BigEndian.Uint32(data[matrix+36:]) >> 16)
+	m.Height = int(binary.BigEndian.Uint32(data[matrix+40:]) >> 16)
+	a := int32(binary.BigEndian.Uint32(data[matrix:]))
+	b := int32(binary.BigEndian.Uint32(data[matrix+4:]))
+	switch {
+	case a == 0 && b > 0:
+		m.Rotation = 90
+	case a < 0 && b == 0:
+		m.Rotation = 180
+	case a == 0 && b < 0:
+		m.Rotation = 270
+	}
+}
+
+// decodeMeta sets the fields stored as QuickTime metadata, in the keys and
+// ilst boxes of the meta box. Metadata that cannot be read is ignored.
+func (m *Movie) decodeMeta(r io.ReaderAt, meta bmffBox) {
+	// Unlike the ISO meta box, the QuickTime one usually lacks the version
+	// and flags, and starts with a box header.
+	var header [4]byte
+	if _, err := r.ReadAt(header[:], meta.offset); err != nil {
+		return
+	}
+	if header == [4]byte{} {
+		meta.offset, meta.size = meta.offset+4, meta.size-4
+	}
+	boxes, err := readBoxes(r, meta.offset, meta.offset+meta.size)
+	if err != nil {
+		return
+	}
+
+	var keys []string
+	var ilst bmffBox
+	for _, box := range boxes {
+		switch box.typ {
+		case "keys":
+			data, err := readPayload(r, box, movieMaxBoxSize)
+			if err != nil || len(data) < 8 {
+				return
+			}
+			count := int(binary.BigEndian.Uint32(data[4:8]))
+			for offset := 8; len(keys) < count && offset+8 <= len(data); {
+				size := int(binary.BigEndian.Uint32(data[offset:]))
+				if size < 8 || offset+size > len(data) {
+					return
+				}
+				keys = append(keys, string(data[offset+8:offset+size]))
+				offset += size
+			}
+		case "ilst":
+			ilst = box
+		}
+	}
+	if len(keys) == 0 || ilst.size == 0 {
+		return
+	}
+
+	items, err := readBoxes(r, ilst.offset, ilst.offset+ilst.size)
+	if err != nil {
+		return
+	}
+	values := make(map[string]string)
+	for _, item := range items {
+		// Items are typed by the 1-based index of their key.
+		index := int(binary.BigEndian.Uint32([]byte(item.typ)))
+		if index < 1 || index > len(keys) {
+			continue
+		}
+		dataBox, ok, err := findBox(r, item.offset, item.offset+item.size, "data")
+		if err != nil || !ok {
+			continue
+		}
+		data, err := readPayload(r, dataBox, movieMaxBoxSize)
+		if err != nil || len(data) < 8 {
+			continue
+		}
+		values[keys[index-1]] = string(data[8:]) // after the type and locale
+	}
+
+	if v, ok := values[movieKeyMake]; ok {
+		m.Make = strings.TrimSpace(v)
+	}
+	if v, ok := values[movieKeyModel]; ok {
+		m.Model = strings.TrimSpace(v)
+	}
+	if v, ok := values[movieKeyLocation]; ok && m.GPS == nil {
+		m.GPS = parseISO6709(v)
+	}
+	if v, ok := values[movieKeyCreationDate]; ok && m.CreationTime.IsZero() {
+		if t, err := time.Parse("2006-01-02T15:04:05-0700", strings.TrimSpace(v)); err == nil {
+			m.CreationTime = t
+		}
+	}
+}
+
+// iso6709Pattern matches a location in the decimal degrees form of ISO 6709,
+// e.g. "+48.8566+002.3522+035.000/".
+var iso6709Pattern = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)?`)
+
+// parseISO6709 returns the location of an ISO 6709 string, or nil if it
+// cannot be parsed.
+func parseISO6709(s string) *GPSInfo {
+	m := iso6709Pattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil
+	}
+	gps := &GPSInfo{}
+	gps.Latitude, _ = strconv.ParseFloat(m[1], 64)
+	gps.Longitude, _ = strconv.ParseFloat(m[2], 64)
+	if m[3] != "" {
+		gps.Altitude, _ = strconv.ParseFloat(m[3], 64)
+	}
+	return gps
+}
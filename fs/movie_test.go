@@ -0,0 +1,223 @@
+package fs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildBox builds a box of an ISO base media file.
+func buildBox(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := binary.BigEndian.AppendUint32(nil, uint32(size))
+	b = append(b, typ...)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// buildMvhd builds the payload of a version 0 movie header box.
+func buildMvhd(created time.Time, timescale uint32, duration uint32) []byte {
+	b := make([]byte, 4) // version and flags
+	seconds := uint32(created.Unix() + quickTimeEpochOffset)
+	b = binary.BigEndian.AppendUint32(b, seconds)
+	b = binary.BigEndian.AppendUint32(b, seconds)
+	b = binary.BigEndian.AppendUint32(b, timescale)
+	b = binary.BigEndian.AppendUint32(b, duration)
+	return append(b, make([]byte, 80)...)
+}
+
+// buildTrak builds a track box with the given handler type, sample entry
+// type, rotation in degrees and dimensions.
+func buildTrak(handler, codec string, rotation int, width, height uint32) []byte {
+	tkhd := make([]byte, 40) // version, flags, times, track ID, duration and reserved fields
+	var a, b, c, d int32 = 1, 0, 0, 1
+	switch rotation {
+	case 90:
+		a, b, c, d = 0, 1, -1, 0
+	case 180:
+		a, d = -1, -1
+	case 270:
+		a, b, c, d = 0, -1, 1, 0
+	}
+	for _, v := range []int32{a << 16, b << 16, 0, c << 16, d << 16, 0, 0, 0, 1 << 30} {
+		tkhd = binary.BigEndian.AppendUint32(tkhd, uint32(v))
+	}
+	tkhd = binary.BigEndian.AppendUint32(tkhd, width<<16)
+	tkhd = binary.BigEndian.AppendUint32(tkhd, height<<16)
+
+	hdlr := append(make([]byte, 8), handler...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+	stsd := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	stsd = append(stsd, buildBox(codec, make([]byte, 78))...)
+
+	return buildBox("trak",
+		buildBox("tkhd", tkhd),
+		buildBox("mdia",
+			buildBox("hdlr", hdlr),
+			buildBox("minf", buildBox("stbl", buildBox("stsd", stsd)))))
+}
+
+// buildKeysMeta builds a QuickTime meta box holding the given metadata,
+// given as alternating keys and values.
+func buildKeysMeta(pairs ...string) []byte {
+	keys := []byte{0, 0, 0, 0}
+	keys = binary.BigEndian.AppendUint32(keys, uint32(len(pairs)/2))
+	var ilst []byte
+	for i := 0; i+1 < len(pairs); i += 2 {
+		keys = append(keys, buildBox("mdta", []byte(pairs[i]))...)
+		index := string(binary.BigEndian.AppendUint32(nil, uint32(i/2+1)))
+		data := append([]byte{0, 0, 0, 1, 0, 0, 0, 0}, pairs[i+1]...)
+		ilst = append(ilst, buildBox(index, buildBox("data", data))...)
+	}
+	return buildBox("meta",
+		buildBox("hdlr", append(make([]byte, 8), "mdta\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...)),
+		buildBox("keys", keys),
+		buildBox("ilst", ilst))
+}
+
+func TestReadMovie(t *testing.T) {
+	created := time.Date(2023, 5, 14, 8, 15, 30, 0, time.UTC)
+	dir := t.TempDir()
+
+	t.Run("iphone", func(t *testing.T) {
+		data := append(ftyp("qt  ", "qt  "), buildBox("wide")...)
+		data = append(data, buildBox("mdat", make([]byte, 64))...)
+		data = append(data, buildBox("moov",
+			buildBox("mvhd", buildMvhd(created, 600, 7500)),
+			buildTrak("vide", "hvc1", 90, 1920, 1080),
+			buildTrak("soun", "mp4a", 0, 0, 0),
+			buildKeysMeta(
+				"com.apple.quicktime.location.ISO6709", "+48.8566+002.3522+035.000/",
+				"com.apple.quicktime.make", "Apple",
+				"com.apple.quicktime.model", "iPhone 14",
+				"com.apple.quicktime.creationdate", "2023-05-14T10:15:30+0200",
+			),
+		)...)
+		path := filepath.Join(dir, "IMG_0001.MOV")
+		require.NoError(t, os.WriteFile(path, data, 0644))
+
+		m, err := ReadMovie(path)
+		require.NoError(t, err)
+		assert.Equal(t, 12500*time.Millisecond, m.Duration)
+		assert.Equal(t, created, m.CreationTime)
+		assert.Equal(t, 1920, m.Width)
+		assert.Equal(t, 1080, m.Height)
+		assert.Equal(t, "hvc1", m.Codec)
+		assert.Equal(t, 90, m.Rotation)
+		assert.Equal(t, "Apple", m.Make)
+		assert.Equal(t, "iPhone 14", m.Model)
+		require.NotNil(t, m.GPS)
+		assert.InDelta(t, 48.8566, m.GPS.Latitude, 1e-9)
+		assert.InDelta(t, 2.3522, m.GPS.Longitude, 1e-9)
+		assert.InDelta(t, 35, m.GPS.Altitude, 1e-9)
+	})
+
+	t.Run("android", func(t *testing.T) {
+		xyz := append([]byte{0x00, 0x12, 0x15, 0xC7}, "-33.8688+151.2093/"...)
+		data := append(ftyp("isom", "isom", "mp42"), buildBox("moov",
+			buildBox("mvhd", buildMvhd(time.Unix(-quickTimeEpochOffset, 0), 90000, 270000)),
+			buildBox("udta", buildBox("\xA9xyz", xyz)),
+			buildTrak("soun", "mp4a", 0, 0, 0),
+			buildTrak("vide", "avc1", 270, 3840, 2160),
+		)...)
+		path := filepath.Join(dir, "VID_20230514_101530.mp4")
+		require.NoError(t, os.WriteFile(path, data, 0644))
+
+		m, err := ReadMovie(path)
+		require.NoError(t, err)
+		assert.Equal(t, 3*time.Second, m.Duration)
+		assert.True(t, m.CreationTime.IsZero())
+		assert.Equal(t, 3840, m.Width)
+		assert.Equal(t, 2160, m.Height)
+		assert.Equal(t, "avc1", m.Codec)
+		assert.Equal(t, 270, m.Rotation)
+		assert.Empty(t, m.Make)
+		require.NotNil(t, m.GPS)
+		assert.InDelta(t, -33.8688, m.GPS.Latitude, 1e-9)
+		assert.InDelta(t, 151.2093, m.GPS.Longitude, 1e-9)
+	})
+
+	t.Run("creation date key", func(t *testing.T) {
+		data := append(ftyp("qt  ", "qt  "), buildBox("moov",
+			buildBox("mvhd", make([]byte, 100)),
+			buildKeysMeta("com.apple.quicktime.creationdate", "2023-05-14T10:15:30+0200"),
+		)...)
+		path := filepath.Join(dir, "clip.mov")
+		require.NoError(t, os.WriteFile(path, data, 0644))
+
+		m, err := ReadMovie(path)
+		require.NoError(t, err)
+		assert.True(t, created.Equal(m.CreationTime), m.CreationTime)
+		_, offset := m.CreationTime.Zone()
+		assert.Equal(t, 2*60*60, offset)
+	})
+
+	t.Run("fragmented", func(t *testing.T) {
+		data := append(ftyp("iso6", "iso6"), buildBox("moov", buildBox("mvhd", buildMvhd(created, 1000, 5000)))...)
+		for range bmffMaxBoxes {
+			data = append(data, buildBox("moof")...)
+			data = append(data, buildBox("mdat")...)
+		}
+		path := filepath.Join(dir, "fragmented.mp4")
+		require.NoError(t, os.WriteFile(path, data, 0644))
+
+		m, err := ReadMovie(path)
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Second, m.Duration)
+	})
+}
+
+func TestReadMovieErrors(t *testing.T) {
+	dir := t.TempDir()
+	createTree(t, dir, map[string]string{
+		"photo.jpg":     string(buildJPEG(nil, 16, 12)),
+		"no-moov.mp4":   string(append(ftyp("isom", "isom"), buildBox("mdat", make([]byte, 16))...)),
+		"truncated.mp4": string(append(ftyp("isom", "isom"), "\x00\x00\x10\x00moov"...)),
+	})
+
+	_, err := ReadMovie(filepath.Join(dir, "photo.jpg"))
+	assert.ErrorIs(t, err, ErrNoMovie)
+
+	_, err = ReadMovie(filepath.Join(dir, "no-moov.mp4"))
+	assert.ErrorIs(t, err, ErrNoMovie)
+
+	_, err = ReadMovie(filepath.Join(dir, "truncated.mp4"))
+	assert.ErrorIs(t, err, errInvalidBMFF)
+
+	_, err = ReadMovie(filepath.Join(dir, "missing.mp4"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileInfoMovie(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clip.m4v")
+	data := append(ftyp("M4V ", "M4V ", "mp42"), buildBox("moov",
+		buildBox("mvhd", buildMvhd(time.Date(2023, 5, 14, 8, 15, 30, 0, time.UTC), 1000, 1500)),
+	)...)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	info, err := NewFileInfo(path)
+	require.NoError(t, err)
+	m, err := info.Movie()
+	require.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, m.Duration)
+
+	cached, err := info.Movie()
+	require.NoError(t, err)
+	assert.Same(t, m, cached)
+
+	dirInfo, err := NewFileInfo(dir)
+	require.NoError(t, err)
+	_, err = dirInfo.Movie()
+	assert.ErrorIs(t, err, ErrNoMovie)
+}
@@ -0,0 +1,26 @@
+//go:build darwin
+
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// This file provides reflinks on Darwin, using clonefile(2) supported by APFS.
+
+// reflink creates a file at dst sharing the data blocks of the file at src.
+// The file at dst must not exist.
+func reflink(src, dst string) error {
+	err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV) {
+			err = fmt.Errorf("%w: %w", ErrReflinkUnsupported, err)
+		}
+		return &os.LinkError{Op: "reflink", Old: src, New: dst, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// This file provides reflinks on Linux, using the FICLONE ioctl(2) supported
+// by copy-on-write file systems such as Btrfs and XFS.
+
+// reflink creates a file at dst sharing the data blocks of the file at src.
+// The file at dst must not exist.
+func reflink(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = unix.IoctlFileClone(int(out.Fd()), int(in.Fd()))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOTTY) ||
+			errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EINVAL) {
+			err = fmt.Errorf("%w: %w", ErrReflinkUnsupported, err)
+		}
+		return &os.LinkError{Op: "reflink", Old: src, New: dst, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package fs
+
+import "os"
+
+// This file provides the fallback for the systems without reflink support.
+
+// reflink always fails with ErrReflinkUnsupported.
+func reflink(src, dst string) error {
+	return &os.LinkError{Op: "reflink", Old: src, New: dst, Err: ErrReflinkUnsupported}
+}
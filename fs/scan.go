@@ -0,0 +1,293 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// This file provides a concurrent scanner that builds FileInfo entries for a
+// whole tree using a bounded pool of workers. It is meant for large archives
+// and slow storage (spinning disks, network shares) where sequential stat
+// calls, as performed by Walk, dominate the scan time.
+
+// ScanOptions controls the behavior of Scan.
+type ScanOptions struct {
+	// WalkOptions selects which entries are scanned, with the same semantics
+	// as for Walk.
+	WalkOptions
+
+	// Workers is the number of concurrent workers reading directories and
+	// retrieving file metadata. A value of 0 or less uses runtime.NumCPU().
+	Workers int
+
+	// Ordered sorts the scanned entries and errors by path, in the same order
+	// as Walk visits them. When false, they are returned in completion order.
+	Ordered bool
+}
+
+// ScanError records an error encountered while scanning a path.
+type ScanError struct {
+	Path string
+	Err  error
+}
+
+// Error returns the error message, prefixed with the path.
+func (e *ScanError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// ScanResult contains the outcome of a Scan.
+type ScanResult struct {
+	Entries []FileInfo   // scanned files and directories, including the root
+	Errors  []*ScanError // per-path errors that did not abort the scan
+}
+
+// Scan scans the file tree rooted at root with a bounded pool of workers and
+// returns the FileInfo entries of all files and directories, including root.
+// Errors reading a directory or describing an entry are collected in the
+// result and do not abort the scan. An error is only returned if root cannot
+// be scanned or ctx is cancelled.
+func Scan(ctx context.Context, root string, opts ScanOptions) (*ScanResult, error) {
+	resolvedRoot, err := Resolve(root)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(resolvedRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	s := scanner{
+		opts:    opts,
+		result:  &ScanResult{},
+		visited: make(map[string]bool),
+	}
+	s.queue.cond = sync.NewCond(&s.queue.mu)
+	s.queue.push(scanJob{path: resolvedRoot, info: info})
+
+	// Wake up idle workers when the context is cancelled.
+	stop := context.AfterFunc(ctx, s.queue.cancel)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.Ordered {
+		sort.Slice(s.result.Entries, func(i, j int) bool {
+			return comparePaths(s.result.Entries[i].Abs(), s.result.Entries[j].Abs()) < 0
+		})
+		sort.Slice(s.result.Errors, func(i, j int) bool {
+			return comparePaths(s.result.Errors[i].Path, s.result.Errors[j].Path) < 0
+		})
+	}
+	return s.result, nil
+}
+
+// scanJob is a unit of work for the scanner. If info is nil, the entry at
+// path still has to be described; otherwise it is ready to be recorded.
+type scanJob struct {
+	path  string
+	entry os.DirEntry
+	info  os.FileInfo
+	depth int
+}
+
+// scanner holds the state of a single Scan call.
+type scanner struct {
+	opts  ScanOptions
+	queue scanQueue
+
+	mu      sync.Mutex
+	result  *ScanResult
+	visited map[string]bool // resolved directories already scanned
+}
+
+// work processes jobs until the queue is drained or cancelled.
+func (s *scanner) work(ctx context.Context) {
+	for {
+		job, ok := s.queue.pop()
+		if !ok {
+			return
+		}
+		if ctx.Err() == nil {
+			s.process(job)
+		}
+		s.queue.done()
+	}
+}
+
+// process describes the entry of a job, records it and, for directories,
+// queues a job for each child entry.
+func (s *scanner) process(job scanJob) {
+	info := job.info
+	if info == nil {
+		var (
+			ok  bool
+			err error
+		)
+		info, ok, err = s.opts.stat(job.entry, job.path)
+		if err != nil {
+			s.addError(job.path, err)
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+
+	fi, err := newFileInfoFromFileInfo(info, job.path)
+	if err != nil {
+		s.addError(job.path, err)
+		return
+	}
+	s.addEntry(fi)
+
+	if !info.IsDir() || (s.opts.MaxDepth > 0 && job.depth >= s.opts.MaxDepth) {
+		return
+	}
+
+	if s.opts.FollowSymlinks {
+		realPath, err := filepath.EvalSymlinks(job.path)
+		if err != nil {
+			s.addError(job.path, err)
+			return
+		}
+		if !s.visit(realPath) {
+			return
+		}
+	}
+
+	entries, err := os.ReadDir(job.path)
+	if err != nil {
+		s.addError(job.path, err)
+		return
+	}
+	for _, entry := range entries {
+		s.queue.push(scanJob{
+			path:  filepath.Join(job.path, entry.Name()),
+			entry: entry,
+			depth: job.depth + 1,
+		})
+	}
+}
+
+// visit marks a resolved directory as scanned and reports whether it was
+// not scanned before.
+func (s *scanner) visit(realPath string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.visited[realPath] {
+		return false
+	}
+	s.visited[realPath] = true
+	return true
+}
+
+// addEntry records a scanned entry.
+func (s *scanner) addEntry(fi FileInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.result.Entries = append(s.result.Entries, fi)
+}
+
+// addError records an error for path.
+func (s *scanner) addError(path string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.result.Errors = append(s.result.Errors, &ScanError{Path: path, Err: err})
+}
+
+// scanQueue is an unbounded job queue shared by the scanner workers.
+// Workers both consume and produce jobs, so the queue tracks the number of
+// jobs in flight to know when the scan is complete.
+type scanQueue struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	jobs      []scanJob
+	pending   int // jobs queued or being processed
+	cancelled bool
+}
+
+// push adds a job to the queue.
+func (q *scanQueue) push(job scanJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = append(q.jobs, job)
+	q.pending++
+	q.cond.Signal()
+}
+
+// pop removes a job from the queue, waiting for one to become available.
+// It returns false once all jobs are done or the queue is cancelled.
+func (q *scanQueue) pop() (scanJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.jobs) == 0 && q.pending > 0 && !q.cancelled {
+		q.cond.Wait()
+	}
+	if len(q.jobs) == 0 || q.cancelled {
+		return scanJob{}, false
+	}
+	// Process the most recently queued job first to keep the queue short.
+	job := q.jobs[len(q.jobs)-1]
+	q.jobs = q.jobs[:len(q.jobs)-1]
+	return job, true
+}
+
+// done marks a popped job as processed.
+func (q *scanQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+// cancel wakes up all waiting workers and makes pop return false.
+func (q *scanQueue) cancel() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cancelled = true
+	q.cond.Broadcast()
+}
+
+// comparePaths compares two paths element by element, so that a directory
+// sorts directly before its contents, as in a depth-first walk.
+func comparePaths(a, b string) int {
+	as := strings.Split(a, string(filepath.Separator))
+	bs := strings.Split(b, string(filepath.Separator))
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
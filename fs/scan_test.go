@@ -0,0 +1,97 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	createTree(t, root, map[string]string{
+		"a.jpg":             "a",
+		"b.txt":             "b",
+		".hidden.jpg":       "h",
+		"album/c.jpg":       "c",
+		"album.d/e.jpg":     "e",
+		"album/sub/d.mp4":   "d",
+		"album/sub/x/y.jpg": "y",
+		"empty/":            "",
+	})
+
+	for _, opts := range []WalkOptions{
+		{},
+		{MaxDepth: 2},
+		{IncludeHidden: true},
+		{Extensions: []string{"jpg"}},
+	} {
+		result, err := Scan(context.Background(), root, ScanOptions{WalkOptions: opts, Workers: 4, Ordered: true})
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+
+		var paths []string
+		for _, entry := range result.Entries {
+			rel, err := filepath.Rel(root, entry.Abs())
+			require.NoError(t, err)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		assert.Equal(t, walkPaths(t, root, opts), paths, "options %+v", opts)
+	}
+}
+
+func TestScanUnordered(t *testing.T) {
+	root := t.TempDir()
+	files := make(map[string]string)
+	for _, dir := range []string{"a", "b", "c", "d"} {
+		for _, name := range []string{"1.jpg", "2.jpg", "3.jpg"} {
+			files[dir+"/"+name] = name
+		}
+	}
+	createTree(t, root, files)
+
+	result, err := Scan(context.Background(), root, ScanOptions{Workers: 3})
+	require.NoError(t, err)
+	assert.Len(t, result.Entries, 1+4+12)
+}
+
+func TestScanErrors(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	createTree(t, root, map[string]string{
+		"album/a.jpg": "a",
+	})
+	dangling := filepath.Join(root, "album", "dangling.jpg")
+	if err := os.Symlink(filepath.Join(root, "missing.jpg"), dangling); err != nil {
+		t.Skip("Skipping symlink test because symlink could not be created")
+	}
+
+	result, err := Scan(context.Background(), root, ScanOptions{
+		WalkOptions: WalkOptions{FollowSymlinks: true},
+		Ordered:     true,
+	})
+	require.NoError(t, err)
+	assert.Len(t, result.Entries, 3)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, dangling, result.Errors[0].Path)
+	assert.ErrorIs(t, result.Errors[0], os.ErrNotExist)
+}
+
+func TestScanCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Scan(ctx, t.TempDir(), ScanOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestComparePaths(t *testing.T) {
+	sep := string(filepath.Separator)
+	assert.Negative(t, comparePaths("a"+sep+"b", "a.d"))
+	assert.Negative(t, comparePaths("a", "a"+sep+"b"))
+	assert.Zero(t, comparePaths("a"+sep+"b", "a"+sep+"b"))
+	assert.Positive(t, comparePaths("b", "a"+sep+"z"))
+}
@@ -0,0 +1,272 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// This file provides content-based media type detection. It identifies common
+// image, video and audio formats by inspecting the leading bytes of a file
+// ("magic bytes"), independently of the file extension, which is frequently
+// wrong or missing for files produced by camera cards, messaging apps and
+// recovery tools.
+
+// sniffLen is the number of leading bytes read to detect the content type.
+const sniffLen = 512
+
+// ContentType describes the type of a file as detected from its content.
+type ContentType struct {
+	MIME string   // MIME type, e.g. "image/jpeg"; empty if the type is unknown
+	Kind Kind     // media kind of the content
+	Exts []string // extensions commonly used for this type, the preferred one first
+}
+
+// Known types detected by DetectContentType.
+var (
+	ContentTypeJPEG      = ContentType{"image/jpeg", KindImage, []string{".jpg", ".jpeg", ".jpe", ".jfif"}}
+	ContentTypePNG       = ContentType{"image/png", KindImage, []string{".png"}}
+	ContentTypeGIF       = ContentType{"image/gif", KindImage, []string{".gif"}}
+	ContentTypeWebP      = ContentType{"image/webp", KindImage, []string{".webp"}}
+	ContentTypeHEIC      = ContentType{"image/heic", KindImage, []string{".heic", ".heif", ".hif"}}
+	ContentTypeHEIF      = ContentType{"image/heif", KindImage, []string{".heif", ".heic", ".hif"}}
+	ContentTypeAVIF      = ContentType{"image/avif", KindImage, []string{".avif"}}
+	ContentTypeTIFF      = ContentType{"image/tiff", KindImage, tiffExts}
+	ContentTypeCR2       = ContentType{"image/x-canon-cr2", KindImage, []string{".cr2"}}
+	ContentTypeCR3       = ContentType{"image/x-canon-cr3", KindImage, []string{".cr3"}}
+	ContentTypeORF       = ContentType{"image/x-olympus-orf", KindImage, []string{".orf"}}
+	ContentTypeRW2       = ContentType{"image/x-panasonic-rw2", KindImage, []string{".rw2", ".rwl"}}
+	ContentTypeRAF       = ContentType{"image/x-fuji-raf", KindImage, []string{".raf"}}
+	ContentTypeMP4       = ContentType{"video/mp4", KindVideo, []string{".mp4", ".m4v"}}
+	ContentTypeM4V       = ContentType{"video/x-m4v", KindVideo, []string{".m4v", ".mp4"}}
+	ContentType3GP       = ContentType{"video/3gpp", KindVideo, []string{".3gp", ".3g2"}}
+	ContentTypeQuickTime = ContentType{"video/quicktime", KindVideo, []string{".mov", ".qt"}}
+	ContentTypeMatroska  = ContentType{"video/x-matroska", KindVideo, []string{".mkv", ".mk3d", ".mka"}}
+	ContentTypeWebM      = ContentType{"video/webm", KindVideo, []string{".webm"}}
+	ContentTypeAVI       = ContentType{"video/x-msvideo", KindVideo, []string{".avi"}}
+	ContentTypeOggVideo  = ContentType{"video/ogg", KindVideo, []string{".ogv", ".ogg"}}
+	ContentTypeMP3       = ContentType{"audio/mpeg", KindAudio, []string{".mp3"}}
+	ContentTypeM4A       = ContentType{"audio/mp4", KindAudio, []string{".m4a", ".m4b", ".mp4"}}
+	ContentTypeFLAC      = ContentType{"audio/flac", KindAudio, []string{".flac"}}
+	ContentTypeOgg       = ContentType{"audio/ogg", KindAudio, []string{".ogg", ".oga", ".opus"}}
+	ContentTypeWAV       = ContentType{"audio/wav", KindAudio, []string{".wav", ".wave"}}
+	ContentTypeAIFF      = ContentType{"audio/aiff", KindAudio, []string{".aif", ".aiff", ".aifc"}}
+)
+
+// tiffExts lists the extensions of TIFF files and of camera RAW formats that
+// use a plain TIFF container, which cannot be told apart by their header.
+var tiffExts = []string{
+	".tif", ".tiff", ".dng", ".nef", ".nrw", ".arw", ".srf", ".sr2", ".pef",
+	".srw", ".3fr", ".erf", ".kdc", ".mef", ".mos", ".iiq",
+}
+
+// IsKnown reports whether the content type was recognized.
+func (c ContentType) IsKnown() bool {
+	return c.MIME != ""
+}
+
+// MatchesExt reports whether ext is one of the extensions commonly used for
+// the content type. The comparison is case-insensitive and the leading dot
+// is optional. An unknown content type matches no extension.
+func (c ContentType) MatchesExt(ext string) bool {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	for _, e := range c.Exts {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectContentType detects the content type of data from its leading bytes.
+// At most the first 512 bytes are considered. If the type is not recognized,
+// the zero ContentType is returned.
+func DetectContentType(data []byte) ContentType {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("\xFF\xD8\xFF")):
+		return ContentTypeJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1A\n")):
+		return ContentTypePNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return ContentTypeGIF
+	case bytes.HasPrefix(data, []byte("FUJIFILMCCD-RAW")):
+		return ContentTypeRAF
+	case bytes.HasPrefix(data, []byte("IIRO")), bytes.HasPrefix(data, []byte("IIRS")),
+		bytes.HasPrefix(data, []byte("MMOR")):
+		return ContentTypeORF
+	case bytes.HasPrefix(data, []byte("IIU\x00")):
+		return ContentTypeRW2
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		if len(data) >= 10 && string(data[8:10]) == "CR" {
+			return ContentTypeCR2
+		}
+		return ContentTypeTIFF
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return ContentTypeFLAC
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return ContentTypeMP3
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return detectOgg(data)
+	case bytes.HasPrefix(data, []byte("\x1A\x45\xDF\xA3")):
+		if bytes.Contains(data, []byte("webm")) {
+			return ContentTypeWebM
+		}
+		return ContentTypeMatroska
+	case len(data) >= 12 && string(data[:4]) == "RIFF":
+		switch string(data[8:12]) {
+		case "WEBP":
+			return ContentTypeWebP
+		case "WAVE":
+			return ContentTypeWAV
+		case "AVI ":
+			return ContentTypeAVI
+		}
+	case len(data) >= 12 && string(data[:4]) == "FORM":
+		switch string(data[8:12]) {
+		case "AIFF", "AIFC":
+			return ContentTypeAIFF
+		}
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return detectFtyp(data)
+	case len(data) >= 8 && isQuickTimeAtom(string(data[4:8])):
+		return ContentTypeQuickTime
+	case isMP3Frame(data):
+		return ContentTypeMP3
+	}
+	return ContentType{}
+}
+
+// detectOgg detects the content type of an Ogg stream from the codec
+// identification header in its first page.
+func detectOgg(data []byte) ContentType {
+	if len(data) >= 35 && string(data[28:35]) == "\x80theora" {
+		return ContentTypeOggVideo
+	}
+	return ContentTypeOgg
+}
+
+// detectFtyp detects the content type of an ISO base media file (MP4,
+// QuickTime, HEIF, ...) from the brands listed in its leading ftyp box.
+// The major brand is considered first, then the compatible brands. Generic
+// brands are only used when no specific brand is found.
+func detectFtyp(data []byte) ContentType {
+	size := int(binary.BigEndian.Uint32(data))
+	if size > len(data) || size < 8 {
+		size = len(data)
+	}
+
+	var brands []string
+	if size >= 12 {
+		brands = append(brands, string(data[8:12]))
+	}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(data[i:i+4]))
+	}
+
+	fallback := ContentType{}
+	for _, brand := range brands {
+		switch brand {
+		case "heic", "heix", "heim", "heis", "hevc", "hevx":
+			return ContentTypeHEIC
+		case "avif", "avis":
+			return ContentTypeAVIF
+		case "crx ":
+			return ContentTypeCR3
+		case "qt  ":
+			return ContentTypeQuickTime
+		case "M4A ", "M4B ", "M4P ":
+			return ContentTypeM4A
+		case "M4V ", "M4VH", "M4VP":
+			return ContentTypeM4V
+		case "mif1", "msf1":
+			if !fallback.IsKnown() {
+				fallback = ContentTypeHEIF
+			}
+		case "isom", "iso2", "iso4", "iso5", "iso6", "mp41", "mp42", "avc1", "dash", "mmp4":
+			if !fallback.IsKnown() {
+				fallback = ContentTypeMP4
+			}
+		default:
+			if strings.HasPrefix(brand, "3gp") || strings.HasPrefix(brand, "3g2") {
+				return ContentType3GP
+			}
+		}
+	}
+	return fallback
+}
+
+// isQuickTimeAtom reports whether typ is the type of an atom commonly found
+// at the start of QuickTime files that lack a ftyp atom.
+func isQuickTimeAtom(typ string) bool {
+	switch typ {
+	case "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+// isMP3Frame reports whether data starts with an MPEG audio layer III frame
+// header, as found in MP3 files without an ID3 tag.
+func isMP3Frame(data []byte) bool {
+	if len(data) < 4 || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+		return false
+	}
+	version := (data[1] >> 3) & 0x03
+	layer := (data[1] >> 1) & 0x03
+	bitrate := data[2] >> 4
+	sampleRate := (data[2] >> 2) & 0x03
+	return version != 0x01 && layer == 0x01 && bitrate != 0x0F && sampleRate != 0x03
+}
+
+// SniffFile detects the content type of the file at path from its leading
+// bytes. Directories and empty files yield the zero ContentType.
+func SniffFile(path string) (ContentType, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ContentType{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return ContentType{}, err
+	}
+	if info.IsDir() {
+		return ContentType{}, nil
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return ContentType{}, err
+	}
+	return DetectContentType(buf[:n]), nil
+}
+
+// Sniff detects the content type of the file described by info.
+func Sniff(info FileInfo) (ContentType, error) {
+	if info.IsDir() {
+		return ContentType{}, nil
+	}
+	return SniffFile(info.Abs())
+}
+
+// ExtMismatch detects the content type of the file described by info and
+// reports whether it disagrees with the file extension. A file whose content
+// type is not recognized is never reported as mismatched.
+func ExtMismatch(info FileInfo) (ContentType, bool, error) {
+	ct, err := Sniff(info)
+	if err != nil {
+		return ContentType{}, false, err
+	}
+	return ct, ct.IsKnown() && !ct.MatchesExt(info.Ext()), nil
+}
@@ -0,0 +1,121 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ftyp builds the leading ftyp box of an ISO base media file.
+func ftyp(major string, compatible ...string) []byte {
+	size := 16 + 4*len(compatible)
+	b := []byte{0, 0, 0, byte(size)}
+	b = append(b, "ftyp"+major+"\x00\x00\x00\x00"...)
+	for _, brand := range compatible {
+		b = append(b, brand...)
+	}
+	return b
+}
+
+func TestDetectContentType(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected ContentType
+	}{
+		{"jpeg", []byte("\xFF\xD8\xFF\xE1\x00\x10Exif"), ContentTypeJPEG},
+		{"png", []byte("\x89PNG\r\n\x1A\n\x00\x00\x00\x0DIHDR"), ContentTypePNG},
+		{"gif", []byte("GIF89a\x01\x00"), ContentTypeGIF},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), ContentTypeWebP},
+		{"heic", ftyp("heic", "mif1", "heic"), ContentTypeHEIC},
+		{"heic compatible", ftyp("mif1", "mif1", "heic"), ContentTypeHEIC},
+		{"heif", ftyp("mif1", "mif1"), ContentTypeHEIF},
+		{"avif", ftyp("avif", "mif1", "avif"), ContentTypeAVIF},
+		{"tiff little endian", []byte("II*\x00\x08\x00\x00\x00"), ContentTypeTIFF},
+		{"tiff big endian", []byte("MM\x00*\x00\x00\x00\x08"), ContentTypeTIFF},
+		{"cr2", []byte("II*\x00\x10\x00\x00\x00CR\x02\x00"), ContentTypeCR2},
+		{"cr3", ftyp("crx ", "crx ", "isom"), ContentTypeCR3},
+		{"orf", []byte("IIRO\x08\x00\x00\x00"), ContentTypeORF},
+		{"rw2", []byte("IIU\x00\x18\x00\x00\x00"), ContentTypeRW2},
+		{"raf", []byte("FUJIFILMCCD-RAW 0201"), ContentTypeRAF},
+		{"mp4", ftyp("isom", "isom", "iso2", "avc1", "mp41"), ContentTypeMP4},
+		{"mov", ftyp("qt  ", "qt  "), ContentTypeQuickTime},
+		{"mov without ftyp", []byte("\x00\x00\x00\x08wide\x00\x00\x00\x00mdat"), ContentTypeQuickTime},
+		{"m4v", ftyp("M4V ", "M4V ", "M4A ", "mp42", "isom"), ContentTypeM4V},
+		{"m4a", ftyp("M4A ", "M4A ", "mp42", "isom"), ContentTypeM4A},
+		{"3gp", ftyp("3gp5", "3gp5", "isom"), ContentType3GP},
+		{"mkv", []byte("\x1A\x45\xDF\xA3\x9F\x42\x86\x81\x01\x42\x82\x88matroska"), ContentTypeMatroska},
+		{"webm", []byte("\x1A\x45\xDF\xA3\x9F\x42\x86\x81\x01\x42\x82\x84webm"), ContentTypeWebM},
+		{"avi", []byte("RIFF\x24\x00\x00\x00AVI LIST"), ContentTypeAVI},
+		{"mp3 id3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), ContentTypeMP3},
+		{"mp3 frame", []byte("\xFF\xFB\x90\x64\x00"), ContentTypeMP3},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), ContentTypeFLAC},
+		{"ogg vorbis", append([]byte("OggS\x00\x02"), make([]byte, 22)...), ContentTypeOgg},
+		{"ogg theora", append(append([]byte("OggS\x00\x02"), make([]byte, 22)...), "\x80theora"...), ContentTypeOggVideo},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ContentTypeWAV},
+		{"aiff", []byte("FORM\x00\x00\x00\x00AIFFCOMM"), ContentTypeAIFF},
+		{"text", []byte("Some text file content.\n"), ContentType{}},
+		{"empty", nil, ContentType{}},
+		{"adts aac", []byte("\xFF\xF1\x50\x80\x00"), ContentType{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, DetectContentType(tc.data))
+		})
+	}
+}
+
+func TestContentTypeMatchesExt(t *testing.T) {
+	assert.True(t, ContentTypeJPEG.MatchesExt(".JPG"))
+	assert.True(t, ContentTypeJPEG.MatchesExt("jpeg"))
+	assert.False(t, ContentTypeJPEG.MatchesExt(".png"))
+	assert.False(t, ContentTypeJPEG.MatchesExt(""))
+	assert.True(t, ContentTypeTIFF.MatchesExt(".NEF"))
+	assert.False(t, ContentType{}.MatchesExt(".jpg"))
+}
+
+func TestExtMismatch(t *testing.T) {
+	dir := t.TempDir()
+	createTree(t, dir, map[string]string{
+		"photo.JPG":  "\xFF\xD8\xFF\xE0\x00\x10JFIF",
+		"photo.png":  "\xFF\xD8\xFF\xE0\x00\x10JFIF",
+		"photo":      "\xFF\xD8\xFF\xE0\x00\x10JFIF",
+		"notes.txt":  "Some text file content.\n",
+		"empty.mp4":  "",
+		"sub/a.jpg/": "",
+	})
+
+	testCases := []struct {
+		name     string
+		mime     string
+		mismatch bool
+	}{
+		{"photo.JPG", "image/jpeg", false},
+		{"photo.png", "image/jpeg", true},
+		{"photo", "image/jpeg", true},
+		{"notes.txt", "", false},
+		{"empty.mp4", "", false},
+		{"sub/a.jpg", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := NewFileInfo(filepath.Join(dir, tc.name))
+			require.NoError(t, err)
+
+			ct, mismatch, err := ExtMismatch(info)
+			require.NoError(t, err)
+			assert.Equal(t, tc.mime, ct.MIME)
+			assert.Equal(t, tc.mismatch, mismatch)
+		})
+	}
+
+	t.Run("non-existing file", func(t *testing.T) {
+		_, err := SniffFile(filepath.Join(dir, "missing.jpg"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,76 @@
+package fs
+
+import (
+	"context"
+	"time"
+)
+
+// This file provides the detection of files that are still being written,
+// such as files dropped into a watched folder by a download or a camera
+// tethering tool, so that they are only processed once complete.
+
+// defaultQuietPeriod is the default duration during which a file must not
+// change to be considered stable.
+const defaultQuietPeriod = 2 * time.Second
+
+// StableOptions controls the behavior of WaitStable.
+type StableOptions struct {
+	// QuietPeriod is the duration during which the size and the modification
+	// time of the file must not change. A value of 0 or less uses 2 seconds.
+	QuietPeriod time.Duration
+
+	// PollInterval is the interval between two checks of the file.
+	// A value of 0 or less uses a quarter of the quiet period.
+	PollInterval time.Duration
+}
+
+// WaitStable waits until the Size and the LastWriteTime of the file at path
+// stop changing for the quiet period, and returns its final FileInfo. On
+// Linux, it also waits until no process holds the file open for writing:
+// writers that opened the file before the call are found in /proc, when
+// their descriptors are readable, and the later ones are reported by inotify
+// from their first modification until they close the file. An error is
+// returned if the file cannot be described, for instance because it was
+// removed, or if ctx is cancelled.
+func WaitStable(ctx context.Context, path string, opts StableOptions) (FileInfo, error) {
+	quietPeriod := opts.QuietPeriod
+	if quietPeriod <= 0 {
+		quietPeriod = defaultQuietPeriod
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = quietPeriod / 4
+	}
+
+	info, err := NewFileInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The monitor is optional: without it, only the quiet period is used.
+	monitor, _ := newWriteMonitor(info.Abs())
+	defer monitor.close()
+
+	lastChange := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := NewFileInfo(info.Abs())
+		if err != nil {
+			return nil, err
+		}
+		if current.Size() != info.Size() || !current.LastWriteTime().Equal(info.LastWriteTime()) || monitor.writing() {
+			lastChange = time.Now()
+		}
+		info = current
+		if time.Since(lastChange) >= quietPeriod {
+			return info, nil
+		}
+	}
+}
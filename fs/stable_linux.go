@@ -0,0 +1,145 @@
+//go:build linux
+
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// This file provides the detection of files open for writing on Linux, using
+// the IN_MODIFY and IN_CLOSE_WRITE events of inotify(7), and the descriptors
+// listed in /proc for the writers that opened the file before it was watched.
+
+// writeMonitor tracks whether a file is being written.
+type writeMonitor struct {
+	fd       int
+	dev, ino uint64 // identity of the file
+	open     bool   // held open by a writer, or modified since last closed by one
+	buf      []byte
+}
+
+// newWriteMonitor starts monitoring the writes to the file at path.
+func newWriteMonitor(path string) (*writeMonitor, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, path, unix.IN_MODIFY|unix.IN_CLOSE_WRITE); err != nil {
+		_ = unix.Close(fd)
+		return nil, &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		_ = unix.Close(fd)
+		return nil, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	m := &writeMonitor{
+		fd:  fd,
+		dev: uint64(stat.Dev),
+		ino: uint64(stat.Ino),
+		buf: make([]byte, 64*unix.SizeofInotifyEvent),
+	}
+	// A writer that opened the file earlier and stays idle triggers no event.
+	m.open = openForWriting(m.dev, m.ino)
+	return m, nil
+}
+
+// writing reports whether the file is held open by a writer, or was modified
+// and not closed since, by consuming the pending events.
+func (m *writeMonitor) writing() bool {
+	if m == nil {
+		return false
+	}
+	closed := false
+	for {
+		n, err := unix.Read(m.fd, m.buf)
+		if err != nil || n < unix.SizeofInotifyEvent {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			break
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&m.buf[offset]))
+			offset += unix.SizeofInotifyEvent + int(raw.Len)
+			switch {
+			case raw.Mask&unix.IN_MODIFY != 0:
+				m.open, closed = true, false
+			case raw.Mask&unix.IN_CLOSE_WRITE != 0:
+				m.open, closed = false, true
+			}
+		}
+	}
+	// Other writers may still hold the file open after one closed it.
+	if closed {
+		m.open = openForWriting(m.dev, m.ino)
+	}
+	return m.open
+}
+
+// openForWriting reports whether a process holds the file with the given
+// device and inode numbers open for writing, by scanning the descriptors
+// listed in /proc. Only the processes whose descriptors are readable, usually
+// those of the same user, are seen.
+func openForWriting(dev, ino uint64) bool {
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return false
+	}
+	for _, proc := range procs {
+		if _, err := strconv.Atoi(proc.Name()); err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", proc.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			var stat unix.Stat_t
+			if err := unix.Stat(filepath.Join(fdDir, fd.Name()), &stat); err != nil {
+				continue
+			}
+			if uint64(stat.Dev) != dev || uint64(stat.Ino) != ino {
+				continue
+			}
+			if writableFd(filepath.Join("/proc", proc.Name(), "fdinfo", fd.Name())) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writableFd reports whether the descriptor described by the fdinfo file at
+// path is open for writing, according to its flags.
+func writableFd(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for line := range bytes.Lines(data) {
+		value, ok := bytes.CutPrefix(line, []byte("flags:"))
+		if !ok {
+			continue
+		}
+		flags, err := strconv.ParseUint(string(bytes.TrimSpace(value)), 8, 64)
+		return err == nil && flags&unix.O_ACCMODE != unix.O_RDONLY
+	}
+	return false
+}
+
+// close stops monitoring the file.
+func (m *writeMonitor) close() {
+	if m != nil {
+		_ = unix.Close(m.fd)
+	}
+}
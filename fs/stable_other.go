@@ -0,0 +1,24 @@
+//go:build !linux
+
+package fs
+
+import "errors"
+
+// This file provides the fallback for the systems without a way to detect
+// files open for writing.
+
+// writeMonitor is not supported; a nil *writeMonitor never reports writes.
+type writeMonitor struct{}
+
+// newWriteMonitor always fails with errors.ErrUnsupported.
+func newWriteMonitor(path string) (*writeMonitor, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// writing always returns false.
+func (m *writeMonitor) writing() bool {
+	return false
+}
+
+// close does nothing.
+func (m *writeMonitor) close() {}
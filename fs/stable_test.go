@@ -0,0 +1,119 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitStable(t *testing.T) {
+	opts := StableOptions{QuietPeriod: 100 * time.Millisecond, PollInterval: 10 * time.Millisecond}
+
+	t.Run("growing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a.jpg")
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				return
+			}
+			defer f.Close()
+			for range 10 {
+				_, _ = f.Write([]byte("x"))
+				time.Sleep(20 * time.Millisecond)
+			}
+		}()
+
+		info, err := WaitStable(context.Background(), path, opts)
+		require.NoError(t, err)
+		<-done
+		assert.Equal(t, int64(10), info.Size())
+	})
+
+	t.Run("held open", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("Skipping write monitoring test because it requires inotify")
+		}
+		path := filepath.Join(t.TempDir(), "a.jpg")
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := WaitStable(context.Background(), path, opts)
+			result <- err
+		}()
+
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond) // let WaitStable start monitoring
+		_, err = f.Write([]byte("x"))
+		require.NoError(t, err)
+
+		select {
+		case <-result:
+			t.Fatal("file reported stable while open for writing")
+		case <-time.After(3 * opts.QuietPeriod):
+		}
+
+		require.NoError(t, f.Close())
+		select {
+		case err := <-result:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("file not reported stable after being closed")
+		}
+	})
+
+	t.Run("opened before waiting", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("Skipping write monitoring test because it requires /proc")
+		}
+		path := filepath.Join(t.TempDir(), "a.jpg")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		defer f.Close()
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := WaitStable(context.Background(), path, opts)
+			result <- err
+		}()
+
+		select {
+		case <-result:
+			t.Fatal("file reported stable while open for writing")
+		case <-time.After(3 * opts.QuietPeriod):
+		}
+
+		require.NoError(t, f.Close())
+		select {
+		case err := <-result:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("file not reported stable after being closed")
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a.jpg")
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := WaitStable(ctx, path, opts)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := WaitStable(context.Background(), filepath.Join(t.TempDir(), "missing.jpg"), opts)
+		assert.Error(t, err)
+	})
+}
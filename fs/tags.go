@@ -0,0 +1,285 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// This file provides file tagging compatible with the freedesktop.org
+// conventions followed by Linux file managers: the tags of a file are stored
+// as a comma-separated list in its "user.xdg.tags" extended attribute. On
+// platforms and file systems without extended attributes, tags are stored in
+// a hidden index file in the directory of the tagged files.
+
+// tagsIndexName is the name of the per-directory index storing the tags of
+// the files whose file system does not support extended attributes.
+const tagsIndexName = ".tags.json"
+
+// ErrInvalidTag is returned for tags that cannot be stored, such as tags
+// containing a comma, which separates tags in the extended attribute.
+var ErrInvalidTag = errors.New("invalid tag")
+
+// tagsIndexLocks serializes the updates of the tags of the files of each
+// directory, from the read of their tags to the write of the index.
+var tagsIndexLocks = &pathLocks{locks: make(map[string]*pathLock)}
+
+// pathLocks holds a lock per path. A lock is removed once no caller holds or
+// waits for it.
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+// pathLock is the lock of a path, with the number of its holders and waiters.
+type pathLock struct {
+	sync.Mutex
+	users int
+}
+
+// lock acquires the lock of path, and returns the function releasing it.
+func (p *pathLocks) lock(path string) (unlock func()) {
+	p.mu.Lock()
+	l, ok := p.locks[path]
+	if !ok {
+		l = &pathLock{}
+		p.locks[path] = l
+	}
+	l.users++
+	p.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if l.users--; l.users == 0 {
+			delete(p.locks, path)
+		}
+	}
+}
+
+// Tags returns the tags of the file at path, in the order they were added.
+// It returns no tags and no error if the file is not tagged.
+func Tags(path string) ([]string, error) {
+	absPath, err := absolute(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := getxattr(absPath, XattrTags)
+	switch {
+	case err == nil:
+		return parseTags(string(value)), nil
+	case errors.Is(err, ErrNoXattr):
+		return nil, nil
+	case errors.Is(err, ErrXattrUnsupported):
+		return indexedTags(absPath)
+	default:
+		return nil, err
+	}
+}
+
+// TagsOf returns the tags of the file described by info. Unlike Tags, it
+// reads them from the extended attributes cached by info.
+func TagsOf(info FileInfo) ([]string, error) {
+	xattrs, err := info.Xattrs()
+	if errors.Is(err, ErrXattrUnsupported) {
+		return indexedTags(info.Abs())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseTags(string(xattrs[XattrTags])), nil
+}
+
+// SetTags replaces the tags of the file at path. Leading and trailing spaces
+// are trimmed, and empty and repeated tags are ignored. Setting no tags
+// removes the tags of the file.
+func SetTags(path string, tags ...string) error {
+	return updateTags(path, func([]string) []string {
+		return tags
+	})
+}
+
+// AddTags adds tags to the file at path, keeping its existing tags.
+func AddTags(path string, tags ...string) error {
+	return updateTags(path, func(existing []string) []string {
+		return append(existing, tags...)
+	})
+}
+
+// RemoveTags removes tags from the file at path. Tags the file does not
+// have are ignored.
+func RemoveTags(path string, tags ...string) error {
+	removed := normalizeTags(tags)
+	return updateTags(path, func(existing []string) []string {
+		return slices.DeleteFunc(existing, func(tag string) bool {
+			return slices.Contains(removed, tag)
+		})
+	})
+}
+
+// updateTags replaces the tags of the file at path with the result of update,
+// given its existing tags. Concurrent updates of the files of a directory are
+// serialized, so that none of them is lost.
+func updateTags(path string, update func(existing []string) []string) error {
+	absPath, err := absolute(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return err
+	}
+
+	unlock := tagsIndexLocks.lock(filepath.Join(filepath.Dir(absPath), tagsIndexName))
+	defer unlock()
+
+	existing, err := Tags(absPath)
+	if err != nil {
+		return err
+	}
+	tags := normalizeTags(update(existing))
+	for _, tag := range tags {
+		if strings.Contains(tag, ",") {
+			return fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		err = removexattr(absPath, XattrTags)
+		if errors.Is(err, ErrNoXattr) {
+			return nil
+		}
+	} else {
+		err = setxattr(absPath, XattrTags, []byte(strings.Join(tags, ",")))
+	}
+	if errors.Is(err, ErrXattrUnsupported) {
+		return setIndexedTags(absPath, tags)
+	}
+	return err
+}
+
+// FindByTag walks the tree rooted at root and returns the files and
+// directories having all the given tags, in walk order. Entries whose tags
+// cannot be read are skipped.
+func FindByTag(ctx context.Context, root string, opts WalkOptions, tags ...string) ([]FileInfo, error) {
+	wanted := normalizeTags(tags)
+	var found []FileInfo
+	err := Walk(ctx, root, opts, func(path string, info FileInfo, err error) error {
+		if err != nil || info.Name() == tagsIndexName {
+			return nil
+		}
+		fileTags, err := TagsOf(info)
+		if err != nil {
+			return nil
+		}
+		for _, tag := range wanted {
+			if !slices.Contains(fileTags, tag) {
+				return nil
+			}
+		}
+		found = append(found, info)
+		return nil
+	})
+	return found, err
+}
+
+// CollectTags walks the tree rooted at root and returns the number of files
+// and directories having each tag.
+func CollectTags(ctx context.Context, root string, opts WalkOptions) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := Walk(ctx, root, opts, func(path string, info FileInfo, err error) error {
+		if err != nil || info.Name() == tagsIndexName {
+			return nil
+		}
+		fileTags, err := TagsOf(info)
+		if err != nil {
+			return nil
+		}
+		for _, tag := range fileTags {
+			counts[tag]++
+		}
+		return nil
+	})
+	return counts, err
+}
+
+// parseTags returns the tags of a comma-separated list.
+func parseTags(value string) []string {
+	return normalizeTags(strings.Split(value, ","))
+}
+
+// normalizeTags trims the tags and removes the empty and repeated ones.
+func normalizeTags(tags []string) []string {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// readTagsIndex returns the tags stored in the index of the directory at dir,
+// by file name.
+func readTagsIndex(dir string) (map[string][]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, tagsIndexName))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string][]string)
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("%s: invalid tags index: %w", filepath.Join(dir, tagsIndexName), err)
+	}
+	return index, nil
+}
+
+// indexedTags returns the tags of the file at path stored in the index of
+// its directory.
+func indexedTags(path string) ([]string, error) {
+	index, err := readTagsIndex(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	return index[filepath.Base(path)], nil
+}
+
+// setIndexedTags stores the tags of the file at path in the index of its
+// directory. The index is removed when no file is tagged anymore. The caller
+// must hold the lock of the index.
+func setIndexedTags(path string, tags []string) error {
+	dir := filepath.Dir(path)
+	index, err := readTagsIndex(dir)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		delete(index, filepath.Base(path))
+	} else {
+		index[filepath.Base(path)] = tags
+	}
+
+	indexPath := filepath.Join(dir, tagsIndexName)
+	if len(index) == 0 {
+		if err := os.Remove(indexPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(indexPath, data, 0644)
+}
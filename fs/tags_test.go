@@ -0,0 +1,132 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	root := t.TempDir()
+	createTree(t, root, map[string]string{
+		"a.jpg":        "a",
+		"b.jpg":        "b",
+		"trip/c.jpg":   "c",
+		"trip/d.mov":   "d",
+		"other/e.jpg":  "e",
+		"other/f.jpeg": "f",
+	})
+	path := filepath.Join(root, "a.jpg")
+
+	err := SetTags(path, "holiday")
+	if errors.Is(err, ErrXattrUnsupported) {
+		t.Skip("Skipping tags test because extended attributes are not supported")
+	}
+	require.NoError(t, err)
+
+	t.Run("add and remove", func(t *testing.T) {
+		require.NoError(t, AddTags(path, " beach ", "holiday", "", "family"))
+		tags, err := Tags(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"holiday", "beach", "family"}, tags)
+
+		value, err := GetXattr(path, XattrTags)
+		require.NoError(t, err)
+		assert.Equal(t, "holiday,beach,family", string(value))
+
+		require.NoError(t, RemoveTags(path, "beach", "missing"))
+		tags, err = Tags(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"holiday", "family"}, tags)
+
+		info, err := NewFileInfo(path)
+		require.NoError(t, err)
+		tags, err = TagsOf(info)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"holiday", "family"}, tags)
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		require.NoError(t, SetTags(filepath.Join(root, "b.jpg"), "x"))
+		require.NoError(t, SetTags(filepath.Join(root, "b.jpg")))
+		require.NoError(t, SetTags(filepath.Join(root, "b.jpg")))
+		tags, err := Tags(filepath.Join(root, "b.jpg"))
+		require.NoError(t, err)
+		assert.Empty(t, tags)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		path := filepath.Join(root, "b.jpg")
+		var wg sync.WaitGroup
+		for i := range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, AddTags(path, fmt.Sprintf("tag%d", i)))
+			}()
+		}
+		wg.Wait()
+		tags, err := Tags(path)
+		require.NoError(t, err)
+		assert.Len(t, tags, 16)
+		require.NoError(t, SetTags(path))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.ErrorIs(t, SetTags(path, "a,b"), ErrInvalidTag)
+		assert.Error(t, SetTags(filepath.Join(root, "missing.jpg"), "a"))
+	})
+
+	t.Run("query", func(t *testing.T) {
+		require.NoError(t, SetTags(filepath.Join(root, "trip", "c.jpg"), "holiday", "family"))
+		require.NoError(t, SetTags(filepath.Join(root, "trip", "d.mov"), "holiday"))
+		require.NoError(t, SetTags(filepath.Join(root, "other"), "holiday"))
+
+		found, err := FindByTag(context.Background(), root, WalkOptions{}, "holiday", "family")
+		require.NoError(t, err)
+		var paths []string
+		for _, info := range found {
+			paths = append(paths, info.Abs())
+		}
+		assert.Equal(t, []string{path, filepath.Join(root, "trip", "c.jpg")}, paths)
+
+		counts, err := CollectTags(context.Background(), root, WalkOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"holiday": 4, "family": 2}, counts)
+	})
+}
+
+func TestTagsIndex(t *testing.T) {
+	root := t.TempDir()
+	createTree(t, root, map[string]string{"a.jpg": "a", "b.jpg": "b"})
+	pathA := filepath.Join(root, "a.jpg")
+	pathB := filepath.Join(root, "b.jpg")
+	indexPath := filepath.Join(root, tagsIndexName)
+
+	require.NoError(t, setIndexedTags(pathA, []string{"holiday", "beach"}))
+	require.NoError(t, setIndexedTags(pathB, []string{"family"}))
+	assert.FileExists(t, indexPath)
+
+	tags, err := indexedTags(pathA)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"holiday", "beach"}, tags)
+
+	require.NoError(t, setIndexedTags(pathA, nil))
+	tags, err = indexedTags(pathA)
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	require.NoError(t, setIndexedTags(pathB, nil))
+	assert.NoFileExists(t, indexPath)
+
+	require.NoError(t, os.WriteFile(indexPath, []byte("["), 0644))
+	_, err = indexedTags(pathA)
+	assert.Error(t, err)
+}
@@ -0,0 +1,214 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// This file provides a minimal reader for TIFF image file directories (IFDs),
+// the structure used to store EXIF metadata in JPEG, TIFF, HEIF and most
+// camera RAW formats. Only the directories and the values that are needed
+// are read, rather than the whole file.
+
+// TIFF field types.
+const (
+	tiffByte      = 1
+	tiffASCII     = 2
+	tiffShort     = 3
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffUndefined = 7
+	tiffSLong     = 9
+	tiffSRational = 10
+)
+
+// Limits protecting the reader against corrupted or malicious files.
+const (
+	tiffMaxEntries   = 1000    // maximum number of entries in a directory
+	tiffMaxValueSize = 1 << 16 // maximum size of a value, in bytes
+)
+
+// errInvalidTIFF is returned for malformed TIFF structures.
+var errInvalidTIFF = errors.New("invalid TIFF structure")
+
+// tiffReader reads the directories of a TIFF structure.
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// tiffEntry is an entry of a directory, with its value.
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiffIFD is a directory, with its entries by tag.
+type tiffIFD map[uint16]tiffEntry
+
+// newTIFFReader reads the header of the TIFF structure at the start of r, and
+// returns a reader for it together with the offset of its first directory.
+// Besides the standard magic number 42, it accepts the variants used by
+// Olympus ORF and Panasonic RW2 files.
+func newTIFFReader(r io.ReaderAt) (*tiffReader, uint32, error) {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", errInvalidTIFF, err)
+	}
+
+	t := &tiffReader{r: r}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, errInvalidTIFF
+	}
+	switch t.order.Uint16(header[2:4]) {
+	case 42, 0x4F52, 0x5352, 0x55:
+	default:
+		return nil, 0, errInvalidTIFF
+	}
+	return t, t.order.Uint32(header[4:8]), nil
+}
+
+// tiffTypeSize returns the size in bytes of a value of the given type, or 0
+// if the type is not supported.
+func tiffTypeSize(typ uint16) int {
+	switch typ {
+	case tiffByte, tiffASCII, tiffUndefined:
+		return 1
+	case tiffShort:
+		return 2
+	case tiffLong, tiffSLong:
+		return 4
+	case tiffRational, tiffSRational:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// readIFD reads the directory at offset, and returns its entries and the
+// offset of the next directory, 0 if there is none. Entries of unsupported
+// types or with oversized values are skipped.
+func (t *tiffReader) readIFD(offset uint32) (tiffIFD, uint32, error) {
+	var countBuf [2]byte
+	if _, err := t.r.ReadAt(countBuf[:], int64(offset)); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", errInvalidTIFF, err)
+	}
+	count := int(t.order.Uint16(countBuf[:]))
+	if count > tiffMaxEntries {
+		return nil, 0, errInvalidTIFF
+	}
+
+	buf := make([]byte, count*12+4)
+	if _, err := t.r.ReadAt(buf, int64(offset)+2); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", errInvalidTIFF, err)
+	}
+
+	ifd := make(tiffIFD, count)
+	for i := range count {
+		raw := buf[i*12 : i*12+12]
+		tag := t.order.Uint16(raw[0:2])
+		entry := tiffEntry{typ: t.order.Uint16(raw[2:4]), count: t.order.Uint32(raw[4:8])}
+		typeSize := tiffTypeSize(entry.typ)
+		size := int64(typeSize) * int64(entry.count)
+		if typeSize == 0 || size > tiffMaxValueSize {
+			continue
+		}
+		if size <= 4 {
+			entry.value = raw[8 : 8+size]
+		} else {
+			entry.value = make([]byte, size)
+			if _, err := t.r.ReadAt(entry.value, int64(t.order.Uint32(raw[8:12]))); err != nil {
+				continue
+			}
+		}
+		ifd[tag] = entry
+	}
+	return ifd, t.order.Uint32(buf[count*12:]), nil
+}
+
+// stringOf returns the ASCII value of the entry, without trailing NULs and spaces.
+func (t *tiffReader) stringOf(ifd tiffIFD, tag uint16) string {
+	entry, ok := ifd[tag]
+	if !ok || (entry.typ != tiffASCII && entry.typ != tiffUndefined) {
+		return ""
+	}
+	value := entry.value
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(string(value))
+}
+
+// uintOf returns the i-th integer value of the entry.
+func (t *tiffReader) uintOf(ifd tiffIFD, tag uint16, i int) (uint32, bool) {
+	entry, ok := ifd[tag]
+	if !ok || i >= int(entry.count) {
+		return 0, false
+	}
+	switch entry.typ {
+	case tiffByte, tiffUndefined:
+		return uint32(entry.value[i]), true
+	case tiffShort:
+		return uint32(t.order.Uint16(entry.value[i*2:])), true
+	case tiffLong, tiffSLong:
+		return t.order.Uint32(entry.value[i*4:]), true
+	default:
+		return 0, false
+	}
+}
+
+// rationalOf returns the i-th rational value of the entry.
+func (t *tiffReader) rationalOf(ifd tiffIFD, tag uint16, i int) (Rational, bool) {
+	entry, ok := ifd[tag]
+	if !ok || i >= int(entry.count) {
+		return Rational{}, false
+	}
+	switch entry.typ {
+	case tiffRational:
+		num, den := t.order.Uint32(entry.value[i*8:]), t.order.Uint32(entry.value[i*8+4:])
+		return Rational{Num: int64(num), Den: int64(den)}, true
+	case tiffSRational:
+		num, den := int32(t.order.Uint32(entry.value[i*8:])), int32(t.order.Uint32(entry.value[i*8+4:]))
+		return Rational{Num: int64(num), Den: int64(den)}, true
+	default:
+		return Rational{}, false
+	}
+}
+
+// Rational is a fraction, as used by EXIF to store exposure times, apertures
+// or coordinates.
+type Rational struct {
+	Num, Den int64
+}
+
+// Float64 returns the value of the fraction, or 0 if the denominator is 0.
+func (r Rational) Float64() float64 {
+	if r.Den == 0 {
+		return 0
+	}
+	return float64(r.Num) / float64(r.Den)
+}
+
+// String returns the fraction as "num/den", or as an integer if the
+// denominator is 1.
+func (r Rational) String() string {
+	if r.Den == 1 {
+		return fmt.Sprint(r.Num)
+	}
+	return fmt.Sprintf("%d/%d", r.Num, r.Den)
+}
+
+// IsZero reports whether the fraction is unset.
+func (r Rational) IsZero() bool {
+	return r.Num == 0 && r.Den == 0
+}
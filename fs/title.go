@@ -0,0 +1,198 @@
+package fs
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file provides a library of patterns parsing the titles given to files
+// by cameras, phones and applications, such as "IMG_20230514_101530" or
+// "DSC_0123", into a capture date, a device prefix, a sequence number and
+// burst markers. The library is shared by the whole package and can be
+// extended at startup with custom patterns.
+
+// TitleInfo is the information encoded in the title of a file.
+type TitleInfo struct {
+	Pattern    string    // name of the pattern that matched, e.g. "whatsapp"
+	Prefix     string    // device or application prefix, e.g. "IMG", "PXL" or "Screenshot"
+	Time       time.Time // capture date, with the time of day if HasTime; zero if not encoded
+	HasTime    bool      // whether Time includes the time of day
+	Sequence   int       // sequence number, e.g. 123 for "DSC_0123"; -1 if not encoded
+	Burst      bool      // whether the file is a shot of a burst
+	BurstCover bool      // whether the file is the cover shot of its burst
+}
+
+// titleGroups are the named groups recognized in title patterns.
+var titleGroups = []string{
+	"prefix", "year", "month", "day", "hour", "minute", "second", "millisecond", "ampm",
+	"seq", "burst", "cover",
+}
+
+// titlePattern is a named regular expression parsing titles.
+type titlePattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// titleRegistry holds the patterns tried by ParseTitle, in order.
+var titleRegistry = struct {
+	sync.RWMutex
+	patterns []titlePattern
+}{patterns: defaultTitlePatterns()}
+
+// defaultTitlePatterns returns the patterns registered by default, from the
+// most to the least specific.
+func defaultTitlePatterns() []titlePattern {
+	const (
+		date       = `(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`
+		clock      = `(?P<hour>\d{2})(?P<minute>\d{2})(?P<second>\d{2})`
+		burstCover = `(?P<burst>_BURST\d*)?(?P<cover>_COVER)?`
+	)
+	patterns := []struct{ name, expr string }{
+		// IMG-20230101-WA0003, VID-20230101-WA0012
+		{"whatsapp", `^(?P<prefix>IMG|VID|AUD|PTT|STK|DOC)-` + date + `-WA(?P<seq>\d+)`},
+		// Screenshot 2024-01-02 at 10.11.12, Screen Shot 2019-01-02 at 1.02.03 PM (2)
+		{"macos-screenshot", `^(?P<prefix>Screenshot|Screen Shot|Screen Recording) ` +
+			`(?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2}) at ` +
+			`(?P<hour>\d{1,2})\.(?P<minute>\d{2})\.(?P<second>\d{2})(?:\s?(?P<ampm>[AaPp][Mm]))?(?: \((?P<seq>\d+)\))?`},
+		// IMG_20230514_101530, PXL_20240102_101530123, Screenshot_20230101-120000_Chrome,
+		// IMG_20230514_101530_BURST001_COVER
+		{"datetime", `^(?P<prefix>[A-Za-z]+)[_-]` + date + `[_-]` + clock + `(?P<millisecond>\d{3})?` +
+			`(?:[_~](?P<seq>\d{1,3}))?` + burstCover},
+		// 20230514_101530, 20230514_101530(1), 20230514_101530_001 (burst)
+		{"timestamp", `^` + date + `_` + clock + `(?:\((?P<seq>\d+)\)|(?P<burst>_(?:\d{3})))?`},
+		// DSC_0123, DSCF0123, _DSC0123, IMG_0001, IMG_0001_BURST20230514101530_COVER
+		{"dcf", `^(?P<prefix>[A-Za-z_][A-Za-z0-9]{2}[A-Za-z_])(?P<seq>\d{4})` + burstCover + `(?:\D|$)`},
+		// Any date, optionally followed by a time: "Scan 2001-02-03", "2022-12-25 Christmas"
+		{"date", `(?:^|\D)(?P<year>(?:19|20)\d{2})[-_.]?(?P<month>0[1-9]|1[0-2])[-_.]?(?P<day>0[1-9]|[12]\d|3[01])` +
+			`(?:(?:[-_ T.]|\sat\s)(?P<hour>[01]\d|2[0-3])[-_.:h]?(?P<minute>[0-5]\d)[-_.:m]?(?P<second>[0-5]\d))?(?:\D|$)`},
+	}
+
+	result := make([]titlePattern, len(patterns))
+	for i, p := range patterns {
+		result[i] = titlePattern{name: p.name, re: regexp.MustCompile(p.expr)}
+	}
+	return result
+}
+
+// RegisterTitlePattern registers a pattern parsing titles, tried by
+// ParseTitle before the patterns registered earlier and the default ones.
+// The pattern is a regular expression whose named groups capture the parts
+// of the title: "prefix", "year", "month", "day", "hour", "minute",
+// "second", "millisecond", "ampm" (AM or PM), "seq" (sequence number),
+// "burst" and "cover" (burst markers, set when matched). It is safe for
+// concurrent use, but is typically called at startup.
+func RegisterTitlePattern(name, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("title pattern %q: %w", name, err)
+	}
+	for _, group := range re.SubexpNames()[1:] {
+		if group != "" && !slices.Contains(titleGroups, group) {
+			return fmt.Errorf("title pattern %q: unknown group %q", name, group)
+		}
+	}
+
+	titleRegistry.Lock()
+	defer titleRegistry.Unlock()
+	// A new slice is built, so that the patterns read by ParseTitle are never
+	// modified.
+	titleRegistry.patterns = slices.Concat([]titlePattern{{name: name, re: re}}, titleRegistry.patterns)
+	return nil
+}
+
+// ParseTitle parses the title of a file, as returned by FileInfo.Title, with
+// the registered patterns. It returns the information of the first pattern
+// matching the title with a valid date, completed with the prefix, sequence
+// number and burst markers of the first pattern matching it. If no pattern
+// finds a date, the information of the first matching pattern is returned,
+// with a zero Time. It returns false if no pattern matches. Times are
+// returned in the local time zone.
+func ParseTitle(title string) (TitleInfo, bool) {
+	titleRegistry.RLock()
+	patterns := titleRegistry.patterns
+	titleRegistry.RUnlock()
+
+	var first TitleInfo
+	matched := false
+	for _, p := range patterns {
+		info, ok := p.parse(title)
+		if !ok {
+			continue
+		}
+		if info.Time.IsZero() {
+			if !matched {
+				first, matched = info, true
+			}
+			continue
+		}
+		if matched {
+			if info.Prefix == "" {
+				info.Prefix = first.Prefix
+			}
+			if info.Sequence < 0 {
+				info.Sequence = first.Sequence
+			}
+			info.Burst = info.Burst || first.Burst
+			info.BurstCover = info.BurstCover || first.BurstCover
+		}
+		return info, true
+	}
+	return first, matched
+}
+
+// parse parses title with the pattern.
+func (p titlePattern) parse(title string) (TitleInfo, bool) {
+	m := p.re.FindStringSubmatch(title)
+	if m == nil {
+		return TitleInfo{}, false
+	}
+	groups := make(map[string]string)
+	for i, name := range p.re.SubexpNames() {
+		if name != "" && m[i] != "" {
+			groups[name] = m[i]
+		}
+	}
+	number := func(name string) int {
+		n, _ := strconv.Atoi(groups[name])
+		return n
+	}
+
+	info := TitleInfo{
+		Pattern:    p.name,
+		Prefix:     strings.Trim(groups["prefix"], "_-"),
+		Sequence:   -1,
+		Burst:      groups["burst"] != "",
+		BurstCover: groups["cover"] != "",
+	}
+	if seq, ok := groups["seq"]; ok {
+		info.Sequence, _ = strconv.Atoi(seq)
+	}
+
+	if _, ok := groups["year"]; ok {
+		year, month, day := number("year"), number("month"), number("day")
+		hour, minute, second := number("hour"), number("minute"), number("second")
+		switch strings.ToUpper(groups["ampm"]) {
+		case "AM":
+			hour %= 12
+		case "PM":
+			hour = hour%12 + 12
+		}
+
+		// Reject invalid dates and times, e.g. February 30, rather than
+		// letting them overflow.
+		if date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC); date.Day() != day ||
+			int(date.Month()) != month || hour > 23 || minute > 59 || second > 59 {
+			return TitleInfo{}, false
+		}
+		nsec := number("millisecond") * int(time.Millisecond)
+		info.Time = time.Date(year, time.Month(month), day, hour, minute, second, nsec, time.Local)
+		_, info.HasTime = groups["hour"]
+	}
+	return info, true
+}
@@ -0,0 +1,95 @@
+package fs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTitle(t *testing.T) {
+	local := func(year int, month time.Month, day, hour, minute, second, msec int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, msec*int(time.Millisecond), time.Local)
+	}
+
+	testCases := []struct {
+		title    string
+		expected TitleInfo
+	}{
+		{"IMG_20230514_101530", TitleInfo{Pattern: "datetime", Prefix: "IMG", Time: local(2023, 5, 14, 10, 15, 30, 0), HasTime: true, Sequence: -1}},
+		{"VID_20230514_101530_1", TitleInfo{Pattern: "datetime", Prefix: "VID", Time: local(2023, 5, 14, 10, 15, 30, 0), HasTime: true, Sequence: 1}},
+		{"PXL_20240102_101530123", TitleInfo{Pattern: "datetime", Prefix: "PXL", Time: local(2024, 1, 2, 10, 15, 30, 123), HasTime: true, Sequence: -1}},
+		{"PXL_20240102_101530123.MP", TitleInfo{Pattern: "datetime", Prefix: "PXL", Time: local(2024, 1, 2, 10, 15, 30, 123), HasTime: true, Sequence: -1}},
+		{"Screenshot_20230101-120000_Chrome", TitleInfo{Pattern: "datetime", Prefix: "Screenshot", Time: local(2023, 1, 1, 12, 0, 0, 0), HasTime: true, Sequence: -1}},
+		{"IMG_20230514_101530_BURST001_COVER", TitleInfo{Pattern: "datetime", Prefix: "IMG", Time: local(2023, 5, 14, 10, 15, 30, 0), HasTime: true, Sequence: -1, Burst: true, BurstCover: true}},
+		{"IMG_20230514_101530_BURST002", TitleInfo{Pattern: "datetime", Prefix: "IMG", Time: local(2023, 5, 14, 10, 15, 30, 0), HasTime: true, Sequence: -1, Burst: true}},
+		{"IMG-20230101-WA0003", TitleInfo{Pattern: "whatsapp", Prefix: "IMG", Time: local(2023, 1, 1, 0, 0, 0, 0), Sequence: 3}},
+		{"Screenshot 2024-01-02 at 10.11.12", TitleInfo{Pattern: "macos-screenshot", Prefix: "Screenshot", Time: local(2024, 1, 2, 10, 11, 12, 0), HasTime: true, Sequence: -1}},
+		{"Screen Shot 2019-01-02 at 1.02.03 PM (2)", TitleInfo{Pattern: "macos-screenshot", Prefix: "Screen Shot", Time: local(2019, 1, 2, 13, 2, 3, 0), HasTime: true, Sequence: 2}},
+		{"Screen Shot 2019-01-02 at 12.02.03 AM", TitleInfo{Pattern: "macos-screenshot", Prefix: "Screen Shot", Time: local(2019, 1, 2, 0, 2, 3, 0), HasTime: true, Sequence: -1}},
+		{"20230514_101530", TitleInfo{Pattern: "timestamp", Time: local(2023, 5, 14, 10, 15, 30, 0), HasTime: true, Sequence: -1}},
+		{"20230514_101530(2)", TitleInfo{Pattern: "timestamp", Time: local(2023, 5, 14, 10, 15, 30, 0), HasTime: true, Sequence: 2}},
+		{"20230514_101530_004", TitleInfo{Pattern: "timestamp", Time: local(2023, 5, 14, 10, 15, 30, 0), HasTime: true, Sequence: -1, Burst: true}},
+		{"DSC_0123", TitleInfo{Pattern: "dcf", Prefix: "DSC", Sequence: 123}},
+		{"DSCF0042", TitleInfo{Pattern: "dcf", Prefix: "DSCF", Sequence: 42}},
+		{"_DSC0007", TitleInfo{Pattern: "dcf", Prefix: "DSC", Sequence: 7}},
+		{"IMG_0001_BURST20230514101530_COVER", TitleInfo{Pattern: "dcf", Prefix: "IMG", Sequence: 1, Burst: true, BurstCover: true}},
+		{"IMG_0001 (edited)", TitleInfo{Pattern: "dcf", Prefix: "IMG", Sequence: 1}},
+		{"IMG_0001 2021-05-03", TitleInfo{Pattern: "date", Prefix: "IMG", Time: local(2021, 5, 3, 0, 0, 0, 0), Sequence: 1}},
+		{"Scan 2001-02-03", TitleInfo{Pattern: "date", Time: local(2001, 2, 3, 0, 0, 0, 0), Sequence: -1}},
+		{"2022-12-25 18.30.00 Christmas", TitleInfo{Pattern: "date", Time: local(2022, 12, 25, 18, 30, 0, 0), HasTime: true, Sequence: -1}},
+		{"PXL_20230230_101530", TitleInfo{}},
+		{"holiday", TitleInfo{}},
+		{"DSC_01234567", TitleInfo{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			info, ok := ParseTitle(tc.title)
+			assert.Equal(t, tc.expected.Pattern != "", ok)
+			assert.Equal(t, tc.expected, info)
+		})
+	}
+}
+
+func TestRegisterTitlePattern(t *testing.T) {
+	defer func(patterns []titlePattern) {
+		titleRegistry.patterns = patterns
+	}(titleRegistry.patterns)
+
+	_, ok := ParseTitle("frame000042")
+	require.False(t, ok)
+
+	require.NoError(t, RegisterTitlePattern("frames", `^(?P<prefix>frame)(?P<seq>\d+)$`))
+	info, ok := ParseTitle("frame000042")
+	require.True(t, ok)
+	assert.Equal(t, TitleInfo{Pattern: "frames", Prefix: "frame", Sequence: 42}, info)
+
+	// Registered patterns take precedence over the default ones.
+	require.NoError(t, RegisterTitlePattern("dashcam", `^(?P<prefix>IMG)_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`))
+	info, ok = ParseTitle("IMG_20230514_101530")
+	require.True(t, ok)
+	assert.Equal(t, "dashcam", info.Pattern)
+	assert.False(t, info.HasTime)
+
+	// Titles are parsed safely during registrations.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 16 {
+			assert.NoError(t, RegisterTitlePattern(fmt.Sprintf("pattern%d", i), `^never(?P<seq>\d+)$`))
+		}
+	}()
+	for range 64 {
+		info, ok = ParseTitle("DSC_0123")
+		require.True(t, ok)
+		assert.Equal(t, 123, info.Sequence)
+	}
+	wg.Wait()
+
+	assert.Error(t, RegisterTitlePattern("invalid", `(`))
+	assert.Error(t, RegisterTitlePattern("unknown group", `(?P<yaer>\d{4})`))
+}
@@ -0,0 +1,205 @@
+package fs
+
+import (
+	"context"
+	gofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// This file provides a recursive directory walker that yields FileInfo values
+// for a whole tree. It mirrors the semantics of filepath.Walk while adding
+// options commonly needed when traversing media libraries, such as depth
+// limits, symlink handling, hidden-file handling and extension filters.
+
+// SkipDir is used as a return value from a WalkFunc to indicate that the
+// directory named in the call is to be skipped. It is not returned as an
+// error by any function.
+var SkipDir = gofs.SkipDir
+
+// SkipAll is used as a return value from a WalkFunc to indicate that all
+// remaining files and directories are to be skipped. It is not returned as an
+// error by any function.
+var SkipAll = gofs.SkipAll
+
+// WalkFunc is the type of the function called by Walk to visit each file or
+// directory. The path argument is the absolute path of the entry. If an error
+// occurred while reading or describing the entry, info is nil and err
+// describes the problem; returning nil from the function continues the walk.
+type WalkFunc func(path string, info FileInfo, err error) error
+
+// WalkOptions controls which entries are visited by Walk.
+// The zero value walks the whole tree, skips symbolic links and hidden files,
+// and does not filter by extension.
+type WalkOptions struct {
+	// MaxDepth limits how deep the walk descends. The root is at depth 0 and
+	// its direct children at depth 1. A value of 0 or less means no limit.
+	MaxDepth int
+
+	// FollowSymlinks makes the walker resolve symbolic links and visit their
+	// targets. Directory links are descended into, with loop detection.
+	// When false, symbolic links are skipped entirely.
+	FollowSymlinks bool
+
+	// IncludeHidden makes the walker visit hidden files and directories.
+	// A file is hidden if its name starts with a dot or, on platforms that
+	// support it, if it carries the hidden attribute.
+	IncludeHidden bool
+
+	// Extensions restricts the files passed to the WalkFunc to those whose
+	// extension matches one of the given values. The comparison is
+	// case-insensitive and the leading dot is optional. Directories are
+	// always visited regardless of this filter.
+	Extensions []string
+}
+
+// Walk walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root. Entries within a directory are
+// visited in lexical order. The walk stops when fn returns an error other
+// than SkipDir or SkipAll, or when ctx is cancelled, in which case the
+// context error is returned.
+func Walk(ctx context.Context, root string, opts WalkOptions, fn WalkFunc) error {
+	resolvedRoot, err := Resolve(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	info, err := os.Stat(resolvedRoot)
+	if err != nil {
+		return fn(resolvedRoot, nil, err)
+	}
+
+	w := walker{
+		opts:    opts,
+		fn:      fn,
+		visited: make(map[string]bool),
+	}
+
+	err = w.walk(ctx, resolvedRoot, info, 0)
+	if err == SkipDir || err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walker holds the state of a single Walk call.
+type walker struct {
+	opts    WalkOptions
+	fn      WalkFunc
+	visited map[string]bool // resolved directories already descended into
+}
+
+// walk visits path and, if it is a directory, its children.
+func (w *walker) walk(ctx context.Context, path string, info os.FileInfo, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fi, err := newFileInfoFromFileInfo(info, path)
+	if err != nil {
+		return w.fn(path, nil, err)
+	}
+
+	if !info.IsDir() {
+		return w.fn(path, fi, nil)
+	}
+
+	if err := w.fn(path, fi, nil); err != nil {
+		return err
+	}
+
+	if w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+		return nil
+	}
+
+	// Guard against symlink loops and directories reachable through several links.
+	if w.opts.FollowSymlinks {
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return w.fn(path, nil, err)
+		}
+		if w.visited[realPath] {
+			return nil
+		}
+		w.visited[realPath] = true
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return w.fn(path, nil, err)
+	}
+
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+
+		childInfo, ok, err := w.opts.stat(entry, childPath)
+		if err != nil {
+			if err := w.fn(childPath, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if err := w.walk(ctx, childPath, childInfo, depth+1); err != nil {
+			if err == SkipDir {
+				if childInfo.IsDir() {
+					continue
+				}
+				// Returning SkipDir on a file skips the remaining files in the directory.
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// stat returns the os.FileInfo describing the directory entry at path and
+// whether the entry passes the filters of the options.
+func (opts WalkOptions) stat(entry gofs.DirEntry, path string) (os.FileInfo, bool, error) {
+	if entry.Type()&os.ModeSymlink != 0 && !opts.FollowSymlinks {
+		return nil, false, nil
+	}
+
+	var (
+		info os.FileInfo
+		err  error
+	)
+	if entry.Type()&os.ModeSymlink != 0 {
+		info, err = os.Stat(path)
+	} else {
+		info, err = entry.Info()
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !opts.IncludeHidden && isHidden(info) {
+		return nil, false, nil
+	}
+	if !info.IsDir() && !opts.matchExt(entry.Name()) {
+		return nil, false, nil
+	}
+	return info, true, nil
+}
+
+// matchExt reports whether the extension of name passes the Extensions filter.
+func (opts WalkOptions) matchExt(name string) bool {
+	if len(opts.Extensions) == 0 {
+		return true
+	}
+	ext := filepath.Ext(name)
+	for _, e := range opts.Extensions {
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
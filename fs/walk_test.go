@@ -0,0 +1,158 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTree creates the given files below root. Each key is a slash-separated
+// path relative to root and each value is the file content. Keys ending with
+// a slash create directories.
+func createTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			require.NoError(t, os.MkdirAll(path, 0755))
+			continue
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+// walkPaths walks root with the given options and returns the visited paths,
+// relative to root and slash-separated.
+func walkPaths(t *testing.T, root string, opts WalkOptions) []string {
+	t.Helper()
+	var paths []string
+	err := Walk(context.Background(), root, opts, func(path string, info FileInfo, err error) error {
+		require.NoError(t, err)
+		rel, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	require.NoError(t, err)
+	return paths
+}
+
+func TestWalk(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	createTree(t, root, map[string]string{
+		"a.jpg":           "a",
+		"b.TXT":           "b",
+		".hidden.jpg":     "h",
+		"album/c.JPG":     "c",
+		"album/sub/d.mp4": "d",
+		".cache/e.jpg":    "e",
+		"empty/":          "",
+	})
+
+	t.Run("default", func(t *testing.T) {
+		assert.Equal(t, []string{
+			".", "a.jpg", "album", "album/c.JPG", "album/sub", "album/sub/d.mp4", "b.TXT", "empty",
+		}, walkPaths(t, root, WalkOptions{}))
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		assert.Equal(t, []string{
+			".", "a.jpg", "album", "b.TXT", "empty",
+		}, walkPaths(t, root, WalkOptions{MaxDepth: 1}))
+	})
+
+	t.Run("include hidden", func(t *testing.T) {
+		assert.Equal(t, []string{
+			".", ".cache", ".cache/e.jpg", ".hidden.jpg", "a.jpg", "album", "album/c.JPG", "album/sub",
+			"album/sub/d.mp4", "b.TXT", "empty",
+		}, walkPaths(t, root, WalkOptions{IncludeHidden: true}))
+	})
+
+	t.Run("extensions", func(t *testing.T) {
+		assert.Equal(t, []string{
+			".", "a.jpg", "album", "album/c.JPG", "album/sub", "empty",
+		}, walkPaths(t, root, WalkOptions{Extensions: []string{"jpg", ".JPEG"}}))
+	})
+
+	t.Run("file info", func(t *testing.T) {
+		var info FileInfo
+		err := Walk(context.Background(), root, WalkOptions{}, func(path string, fi FileInfo, err error) error {
+			if fi != nil && fi.Name() == "c.JPG" {
+				info = fi
+			}
+			return err
+		})
+		require.NoError(t, err)
+		require.NotNil(t, info)
+		assert.Equal(t, "c", info.Title())
+		assert.Equal(t, ".JPG", info.Ext())
+		assert.Equal(t, filepath.Join(root, "album"), info.Path())
+		assert.Equal(t, int64(1), info.Size())
+		assert.False(t, info.LastWriteTime().IsZero())
+	})
+
+	t.Run("skip dir", func(t *testing.T) {
+		var paths []string
+		err := Walk(context.Background(), root, WalkOptions{}, func(path string, info FileInfo, err error) error {
+			if info.Name() == "album" {
+				return SkipDir
+			}
+			paths = append(paths, info.Name())
+			return nil
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, paths, "c.JPG")
+		assert.Contains(t, paths, "b.TXT")
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		count := 0
+		err := Walk(ctx, root, WalkOptions{}, func(path string, info FileInfo, err error) error {
+			count++
+			cancel()
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("non-existing root", func(t *testing.T) {
+		err := Walk(context.Background(), filepath.Join(root, "missing"), WalkOptions{},
+			func(path string, info FileInfo, err error) error {
+				return err
+			})
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	createTree(t, root, map[string]string{
+		"album/a.jpg": "a",
+	})
+	if err := os.Symlink(filepath.Join(root, "album"), filepath.Join(root, "linked")); err != nil {
+		t.Skip("Skipping symlink test because symlink could not be created")
+	}
+	// A link back to the root creates a loop that must not be followed forever.
+	require.NoError(t, os.Symlink(root, filepath.Join(root, "album", "loop")))
+
+	t.Run("skip symlinks", func(t *testing.T) {
+		assert.Equal(t, []string{
+			".", "album", "album/a.jpg",
+		}, walkPaths(t, root, WalkOptions{}))
+	})
+
+	t.Run("follow symlinks", func(t *testing.T) {
+		assert.Equal(t, []string{
+			".", "album", "album/a.jpg", "album/loop", "linked",
+		}, walkPaths(t, root, WalkOptions{FollowSymlinks: true}))
+	})
+}
@@ -0,0 +1,361 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file provides a recursive file system watcher, reporting the changes
+// within a tree as they happen instead of requiring periodic rescans. It uses
+// inotify on Linux, and otherwise falls back to polling the tree and
+// comparing successive snapshots.
+
+// WatchOp describes the kind of change reported by a WatchEvent.
+type WatchOp uint8
+
+const (
+	WatchCreate WatchOp = iota + 1 // a file or directory was created or moved into the tree
+	WatchModify                    // the content of a file was modified
+	WatchDelete                    // a file or directory was removed or moved out of the tree
+	WatchRename                    // a file or directory was renamed or moved within the tree
+)
+
+// defaultPollInterval is the interval between snapshots of the polling watcher.
+const defaultPollInterval = time.Second
+
+// ErrWatchOverflow is reported on the Errors channel of a Watcher when the
+// system dropped events, typically because they were not consumed fast
+// enough. Changes may have been missed, and a rescan is advised.
+var ErrWatchOverflow = errors.New("watch event queue overflow")
+
+// String returns the lower-case name of the operation, e.g. "create".
+func (op WatchOp) String() string {
+	switch op {
+	case WatchCreate:
+		return "create"
+	case WatchModify:
+		return "modify"
+	case WatchDelete:
+		return "delete"
+	case WatchRename:
+		return "rename"
+	default:
+		return fmt.Sprintf("WatchOp(%d)", int(op))
+	}
+}
+
+// WatchEvent describes a change within a watched tree.
+type WatchEvent struct {
+	Op      WatchOp
+	Path    string   // absolute path of the file, after the change
+	OldPath string   // absolute path of the file before a WatchRename
+	Info    FileInfo // snapshot of the file after the change; nil for WatchDelete or if it vanished
+}
+
+// String returns a human-readable description of the event.
+func (e WatchEvent) String() string {
+	if e.Op == WatchRename {
+		return fmt.Sprintf("%s %s -> %s", e.Op, e.OldPath, e.Path)
+	}
+	return fmt.Sprintf("%s %s", e.Op, e.Path)
+}
+
+// WatchOptions controls the behavior of Watch.
+type WatchOptions struct {
+	// IncludeHidden makes the watcher report changes to hidden files and
+	// within hidden directories, as defined for WalkOptions.
+	IncludeHidden bool
+
+	// Poll forces the polling implementation, even on platforms with native
+	// change notifications. Polling also works on network file systems,
+	// where native notifications are usually not delivered.
+	Poll bool
+
+	// PollInterval is the interval between two snapshots of the tree when
+	// polling. A value of 0 or less uses 1 second.
+	PollInterval time.Duration
+}
+
+// Watcher reports the changes within a watched tree. Events and errors are
+// delivered on the Events and Errors channels, which must be consumed, and
+// which are closed when the watcher stops.
+type Watcher struct {
+	Events <-chan WatchEvent
+	Errors <-chan error
+
+	root string
+	opts WatchOptions
+
+	events chan WatchEvent
+	errors chan error
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
+	closer    func() error // releases the resources of the implementation
+
+	mu   sync.Mutex  // guards stop, set while the context may already close the watcher
+	stop func() bool // stops the cancellation of the watcher by its context
+}
+
+// Watch starts watching the tree rooted at the directory root, recursively.
+// Directories created within the tree are watched automatically. The watcher
+// stops when Close is called or ctx is cancelled.
+func Watch(ctx context.Context, root string, opts WatchOptions) (*Watcher, error) {
+	resolvedRoot, err := Resolve(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(resolvedRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", resolvedRoot)
+	}
+
+	w := &Watcher{
+		root:   resolvedRoot,
+		opts:   opts,
+		events: make(chan WatchEvent, 64),
+		errors: make(chan error, 8),
+		done:   make(chan struct{}),
+	}
+	w.Events = w.events
+	w.Errors = w.errors
+
+	err = errors.ErrUnsupported
+	if !opts.Poll {
+		err = w.watchNative()
+	}
+	if errors.Is(err, errors.ErrUnsupported) {
+		err = w.watchPoll()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = w.Close() })
+	w.mu.Lock()
+	w.stop = stop
+	w.mu.Unlock()
+	return w, nil
+}
+
+// Close stops the watcher and closes its channels. Events not consumed yet
+// are discarded.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		stop := w.stop
+		w.mu.Unlock()
+		if stop != nil {
+			stop()
+		}
+		close(w.done)
+		if w.closer != nil {
+			w.closeErr = w.closer()
+		}
+		w.wg.Wait()
+		close(w.events)
+		close(w.errors)
+	})
+	return w.closeErr
+}
+
+// emit delivers an event, unless the watcher is closed. It reports whether
+// the event was delivered.
+func (w *Watcher) emit(op WatchOp, path, oldPath string) bool {
+	event := WatchEvent{Op: op, Path: path, OldPath: oldPath}
+	if op != WatchDelete {
+		if info, err := NewFileInfoLstat(path); err == nil {
+			event.Info = info
+		}
+	}
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// fail delivers an error, unless the watcher is closed.
+func (w *Watcher) fail(err error) {
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+// ignored reports whether changes to the file at path are not reported,
+// because it is hidden or within a hidden directory.
+func (w *Watcher) ignored(path string) bool {
+	if w.opts.IncludeHidden {
+		return false
+	}
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(name, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// watchPoll starts the polling implementation.
+func (w *Watcher) watchPoll() error {
+	interval := w.opts.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	previous, err := w.snapshot()
+	if err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+			current, err := w.snapshot()
+			if err != nil {
+				w.fail(err)
+				continue
+			}
+			for _, event := range diffSnapshots(previous, current) {
+				if !w.emit(event.Op, event.Path, event.OldPath) {
+					return
+				}
+			}
+			previous = current
+		}
+	}()
+	return nil
+}
+
+// snapshotEntry is the state of a file recorded by the polling watcher.
+type snapshotEntry struct {
+	id      FileID
+	dir     bool
+	size    int64
+	modTime time.Time
+}
+
+// snapshot records the state of the files within the watched tree, by path.
+// Errors describing single entries are ignored.
+func (w *Watcher) snapshot() (map[string]snapshotEntry, error) {
+	entries := make(map[string]snapshotEntry)
+	opts := WalkOptions{IncludeHidden: w.opts.IncludeHidden}
+	err := Walk(context.Background(), w.root, opts, func(path string, info FileInfo, err error) error {
+		if err != nil {
+			if path == w.root {
+				return err
+			}
+			return nil
+		}
+		if path != w.root {
+			entries[path] = snapshotEntry{
+				id:      info.FileID(),
+				dir:     info.IsDir(),
+				size:    info.Size(),
+				modTime: info.LastWriteTime(),
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// diffSnapshots returns the events turning the previous snapshot into the
+// current one. Files that disappeared from a path and appeared at another
+// with the same identity are reported as renamed; the contents of a renamed
+// directory are not reported separately.
+func diffSnapshots(previous, current map[string]snapshotEntry) []WatchEvent {
+	removed := make(map[FileID]string)
+	for path, entry := range previous {
+		if _, ok := current[path]; !ok && !entry.id.IsZero() {
+			removed[entry.id] = path
+		}
+	}
+
+	var creates, modifies, deletes []string
+	renames := make(map[string]string) // old path by new path
+	for path, entry := range current {
+		old, ok := previous[path]
+		switch {
+		case !ok:
+			if oldPath, found := removed[entry.id]; found && !entry.id.IsZero() {
+				renames[path] = oldPath
+				delete(removed, entry.id)
+			} else {
+				creates = append(creates, path)
+			}
+		case !entry.dir && (entry.size != old.size || !entry.modTime.Equal(old.modTime)):
+			modifies = append(modifies, path)
+		}
+	}
+	renamed := make(map[string]bool)
+	for _, old := range renames {
+		renamed[old] = true
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok && !renamed[path] {
+			deletes = append(deletes, path)
+		}
+	}
+
+	var events []WatchEvent
+	for _, path := range sortedKeys(renames) {
+		old := renames[path]
+		parent, oldParent := filepath.Dir(path), filepath.Dir(old)
+		if renames[parent] == oldParent && filepath.Base(path) == filepath.Base(old) {
+			continue // moved with its directory
+		}
+		events = append(events, WatchEvent{Op: WatchRename, Path: path, OldPath: old})
+	}
+	for _, group := range []struct {
+		op    WatchOp
+		paths []string
+	}{{WatchCreate, creates}, {WatchModify, modifies}, {WatchDelete, deletes}} {
+		sortPaths(group.paths)
+		for _, path := range group.paths {
+			events = append(events, WatchEvent{Op: group.op, Path: path})
+		}
+	}
+	return events
+}
+
+// sortedKeys returns the keys of m, sorted as paths.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sortPaths(keys)
+	return keys
+}
+
+// sortPaths sorts paths so that directories come before their contents.
+func sortPaths(paths []string) {
+	slices.SortFunc(paths, comparePaths)
+}
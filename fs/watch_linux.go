@@ -0,0 +1,287 @@
+//go:build linux
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// This file provides the inotify(7) implementation of the watcher on Linux.
+
+// inotifyMask is the set of inotify events watched on every directory.
+const inotifyMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF |
+	unix.IN_ONLYDIR | unix.IN_EXCL_UNLINK
+
+// inotifyMoveTimeout is how long the first half of a rename waits for its
+// other half before it is reported as a move out of the tree.
+const inotifyMoveTimeout = 50 * time.Millisecond
+
+// inotifyWatcher holds the state of the inotify implementation.
+type inotifyWatcher struct {
+	*Watcher
+
+	fd   int
+	file *os.File // wraps fd, so that reads are interrupted by Close
+
+	mu    sync.Mutex
+	paths map[int]string // watched directories by watch descriptor
+
+	moves map[uint32]inotifyMove // first halves of renames, by cookie
+	order []uint32               // cookies of the moves, in the order of the events
+}
+
+// watchNative starts the inotify implementation.
+func (w *Watcher) watchNative() error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+
+	iw := &inotifyWatcher{
+		Watcher: w,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		paths:   make(map[int]string),
+		moves:   make(map[uint32]inotifyMove),
+	}
+	if err := iw.addTree(w.root, false); err != nil {
+		_ = iw.file.Close()
+		return err
+	}
+
+	w.closer = iw.file.Close
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		iw.run()
+	}()
+	return nil
+}
+
+// addTree watches the directory at path and its subdirectories. When report
+// is true, a WatchCreate event is emitted for every entry found below path,
+// since they may have been created before the directory was watched.
+func (iw *inotifyWatcher) addTree(path string, report bool) error {
+	if err := iw.add(path); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+		if iw.ignored(childPath) {
+			continue
+		}
+		if report && !iw.emit(WatchCreate, childPath, "") {
+			return nil
+		}
+		if entry.IsDir() {
+			if err := iw.addTree(childPath, report); err != nil && !errors.Is(err, os.ErrNotExist) {
+				iw.fail(err)
+			}
+		}
+	}
+	return nil
+}
+
+// add watches the directory at path.
+func (iw *inotifyWatcher) add(path string) error {
+	wd, err := unix.InotifyAddWatch(iw.fd, path, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	iw.paths[wd] = path
+	return nil
+}
+
+// forget removes the watch descriptor wd, which the kernel already removed.
+func (iw *inotifyWatcher) forget(wd int) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	delete(iw.paths, wd)
+}
+
+// move updates the paths of the watched directories after the directory at
+// oldPath was renamed to newPath.
+func (iw *inotifyWatcher) move(oldPath, newPath string) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	prefix := oldPath + string(filepath.Separator)
+	for wd, path := range iw.paths {
+		if path == oldPath || strings.HasPrefix(path, prefix) {
+			iw.paths[wd] = newPath + path[len(oldPath):]
+		}
+	}
+}
+
+// remove stops watching the directory at path and its subdirectories, which
+// were moved out of the tree.
+func (iw *inotifyWatcher) remove(path string) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	prefix := path + string(filepath.Separator)
+	for wd, p := range iw.paths {
+		if p == path || strings.HasPrefix(p, prefix) {
+			_, _ = unix.InotifyRmWatch(iw.fd, uint32(wd))
+			delete(iw.paths, wd)
+		}
+	}
+}
+
+// pathOf returns the path of the directory watched by wd.
+func (iw *inotifyWatcher) pathOf(wd int) (string, bool) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	path, ok := iw.paths[wd]
+	return path, ok
+}
+
+// inotifyMove is a half of a rename, waiting for its other half.
+type inotifyMove struct {
+	path    string
+	dir     bool
+	expires time.Time // when the move is considered out of the tree
+}
+
+// run reads and dispatches the inotify events until the watcher is closed.
+func (iw *inotifyWatcher) run() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		// The two halves of a rename are queued together, but may straddle
+		// two reads when the buffer fills up. A half still unpaired is thus
+		// only waited for until it expires.
+		var deadline time.Time
+		if len(iw.order) > 0 {
+			deadline = iw.moves[iw.order[0]].expires
+		}
+		if err := iw.file.SetReadDeadline(deadline); err != nil && !deadline.IsZero() {
+			if !iw.flushMoves(time.Now().Add(inotifyMoveTimeout)) {
+				return
+			}
+		}
+
+		n, err := iw.file.Read(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if !iw.flushMoves(time.Now()) {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				iw.fail(err)
+			}
+			return
+		}
+
+		now := time.Now()
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(raw.Len)]), "\x00")
+			offset = nameStart + int(raw.Len)
+
+			if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+				iw.fail(ErrWatchOverflow)
+				continue
+			}
+			if raw.Mask&unix.IN_IGNORED != 0 {
+				iw.forget(int(raw.Wd))
+				continue
+			}
+			dirPath, ok := iw.pathOf(int(raw.Wd))
+			if !ok || raw.Mask&unix.IN_DELETE_SELF != 0 {
+				continue
+			}
+			path := filepath.Join(dirPath, name)
+			if iw.ignored(path) {
+				continue
+			}
+			isDir := raw.Mask&unix.IN_ISDIR != 0
+
+			delivered := true
+			switch {
+			case raw.Mask&unix.IN_CREATE != 0:
+				delivered = iw.emit(WatchCreate, path, "")
+				if isDir {
+					iw.watchNew(path)
+				}
+			case raw.Mask&unix.IN_MODIFY != 0:
+				delivered = iw.emit(WatchModify, path, "")
+			case raw.Mask&unix.IN_DELETE != 0:
+				delivered = iw.emit(WatchDelete, path, "")
+			case raw.Mask&unix.IN_MOVED_FROM != 0:
+				iw.moves[raw.Cookie] = inotifyMove{path: path, dir: isDir, expires: now.Add(inotifyMoveTimeout)}
+				iw.order = append(iw.order, raw.Cookie)
+			case raw.Mask&unix.IN_MOVED_TO != 0:
+				if from, ok := iw.moves[raw.Cookie]; ok {
+					delete(iw.moves, raw.Cookie)
+					if isDir {
+						iw.move(from.path, path)
+					}
+					delivered = iw.emit(WatchRename, path, from.path)
+				} else {
+					delivered = iw.emit(WatchCreate, path, "")
+					if isDir {
+						iw.watchNew(path)
+					}
+				}
+			}
+			if !delivered {
+				return
+			}
+		}
+		if !iw.flushMoves(now) {
+			return
+		}
+	}
+}
+
+// flushMoves reports the unpaired halves of renames that expired before now
+// as moves out of the tree, and forgets the paired ones. It reports whether
+// the watcher is still open.
+func (iw *inotifyWatcher) flushMoves(now time.Time) bool {
+	pending := iw.order[:0]
+	for _, cookie := range iw.order {
+		from, ok := iw.moves[cookie]
+		if !ok {
+			continue
+		}
+		if from.expires.After(now) {
+			pending = append(pending, cookie)
+			continue
+		}
+		delete(iw.moves, cookie)
+		if from.dir {
+			iw.remove(from.path)
+		}
+		if !iw.emit(WatchDelete, from.path, "") {
+			return false
+		}
+	}
+	iw.order = pending
+	return true
+}
+
+// watchNew watches a directory created or moved into the tree, reporting the
+// entries it already contains.
+func (iw *inotifyWatcher) watchNew(path string) {
+	if err := iw.addTree(path, true); err != nil && !errors.Is(err, os.ErrNotExist) {
+		iw.fail(err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fs
+
+import "errors"
+
+// This file provides the fallback for the systems without a native watcher
+// implementation, which use polling instead.
+
+// watchNative always fails with errors.ErrUnsupported.
+func (w *Watcher) watchNative() error {
+	return errors.ErrUnsupported
+}
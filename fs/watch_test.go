@@ -0,0 +1,162 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitEvent waits for an event with the given operation and path, skipping
+// the other events, and fails the test on timeout.
+func waitEvent(t *testing.T, w *Watcher, op WatchOp, path string) WatchEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-w.Events:
+			require.True(t, ok, "watcher closed")
+			if event.Op == op && event.Path == path {
+				return event
+			}
+		case err := <-w.Errors:
+			require.NoError(t, err)
+		case <-timeout:
+			require.Failf(t, "timeout", "no %s event for %s", op, path)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		name := "native"
+		if poll {
+			name = "poll"
+		}
+		t.Run(name, func(t *testing.T) {
+			root, err := filepath.EvalSymlinks(t.TempDir())
+			require.NoError(t, err)
+			createTree(t, root, map[string]string{"existing/a.jpg": "a"})
+
+			w, err := Watch(context.Background(), root, WatchOptions{Poll: poll, PollInterval: 20 * time.Millisecond})
+			require.NoError(t, err)
+			defer w.Close()
+
+			// Let the polling watcher notice modifications of the same second.
+			step := func() {
+				if poll {
+					time.Sleep(50 * time.Millisecond)
+				}
+			}
+
+			path := filepath.Join(root, "b.jpg")
+			require.NoError(t, os.WriteFile(path, []byte("b"), 0644))
+			event := waitEvent(t, w, WatchCreate, path)
+			require.NotNil(t, event.Info)
+			assert.Equal(t, "b.jpg", event.Info.Name())
+			step()
+
+			require.NoError(t, os.WriteFile(path, []byte("bb"), 0644))
+			// The initial write may also be reported as a modification.
+			for event = waitEvent(t, w, WatchModify, path); event.Info == nil || event.Info.Size() != 2; {
+				event = waitEvent(t, w, WatchModify, path)
+			}
+			step()
+
+			newPath := filepath.Join(root, "existing", "c.jpg")
+			require.NoError(t, os.Rename(path, newPath))
+			event = waitEvent(t, w, WatchRename, newPath)
+			assert.Equal(t, path, event.OldPath)
+			step()
+
+			dir := filepath.Join(root, "new", "nested")
+			require.NoError(t, os.MkdirAll(dir, 0755))
+			waitEvent(t, w, WatchCreate, filepath.Join(root, "new"))
+			step()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "d.jpg"), []byte("d"), 0644))
+			waitEvent(t, w, WatchCreate, filepath.Join(dir, "d.jpg"))
+			step()
+
+			require.NoError(t, os.Remove(newPath))
+			event = waitEvent(t, w, WatchDelete, newPath)
+			assert.Nil(t, event.Info)
+			step()
+
+			// A move out of the tree is reported as a deletion.
+			movedPath := filepath.Join(root, "existing", "a.jpg")
+			require.NoError(t, os.Rename(movedPath, filepath.Join(t.TempDir(), "a.jpg")))
+			waitEvent(t, w, WatchDelete, movedPath)
+			step()
+
+			require.NoError(t, os.WriteFile(filepath.Join(root, ".hidden"), []byte("h"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(root, "e.jpg"), []byte("e"), 0644))
+			for {
+				event := <-w.Events
+				require.NotEqual(t, filepath.Join(root, ".hidden"), event.Path)
+				if event.Path == filepath.Join(root, "e.jpg") {
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWatchClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := Watch(ctx, t.TempDir(), WatchOptions{})
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case _, ok := <-w.Events:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher not closed")
+	}
+	assert.NoError(t, w.Close())
+
+	// A watcher started with a cancelled context closes itself.
+	w, err = Watch(ctx, t.TempDir(), WatchOptions{})
+	require.NoError(t, err)
+	select {
+	case _, ok := <-w.Events:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher not closed")
+	}
+	assert.NoError(t, w.Close())
+
+	_, err = Watch(context.Background(), filepath.Join(t.TempDir(), "missing"), WatchOptions{})
+	assert.Error(t, err)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Now()
+	sep := string(filepath.Separator)
+	previous := map[string]snapshotEntry{
+		sep + "a.jpg":               {id: FileID{1, 1}, size: 1, modTime: now},
+		sep + "b.jpg":               {id: FileID{1, 2}, size: 1, modTime: now},
+		sep + "dir":                 {id: FileID{1, 3}, dir: true},
+		sep + "dir" + sep + "c.jpg": {id: FileID{1, 4}, size: 1, modTime: now},
+		sep + "d.jpg":               {id: FileID{1, 5}, size: 1, modTime: now},
+	}
+	current := map[string]snapshotEntry{
+		sep + "a.jpg":                 {id: FileID{1, 1}, size: 2, modTime: now},
+		sep + "e.jpg":                 {id: FileID{1, 2}, size: 1, modTime: now},
+		sep + "moved":                 {id: FileID{1, 3}, dir: true},
+		sep + "moved" + sep + "c.jpg": {id: FileID{1, 4}, size: 1, modTime: now},
+		sep + "f.jpg":                 {id: FileID{1, 6}, size: 1, modTime: now},
+	}
+
+	assert.Equal(t, []WatchEvent{
+		{Op: WatchRename, Path: sep + "e.jpg", OldPath: sep + "b.jpg"},
+		{Op: WatchRename, Path: sep + "moved", OldPath: sep + "dir"},
+		{Op: WatchCreate, Path: sep + "f.jpg"},
+		{Op: WatchModify, Path: sep + "a.jpg"},
+		{Op: WatchDelete, Path: sep + "d.jpg"},
+	}, diffSnapshots(previous, current))
+}
@@ -0,0 +1,117 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// This file provides access to the extended attributes of files, which
+// desktop environments and applications use to store metadata such as tags,
+// ratings or the URL a file was downloaded from. Extended attributes are
+// supported on Linux and Darwin; on other platforms, every operation fails
+// with ErrXattrUnsupported.
+
+// Well-known extended attributes, as defined by the freedesktop.org
+// recommendations and used by Linux desktop environments.
+const (
+	XattrTags      = "user.xdg.tags"       // comma-separated list of tags
+	XattrComment   = "user.xdg.comment"    // free-form comment
+	XattrOriginURL = "user.xdg.origin.url" // URL the file was downloaded from
+	XattrRating    = "user.baloo.rating"   // rating from 0 to 10, as used by KDE
+)
+
+var (
+	// ErrXattrUnsupported is returned when the platform or the file system
+	// does not support extended attributes.
+	ErrXattrUnsupported = errors.New("extended attributes are not supported")
+
+	// ErrNoXattr is returned when reading an extended attribute that is not set.
+	ErrNoXattr = errors.New("extended attribute not set")
+)
+
+// GetXattr returns the value of the extended attribute name of the file at
+// path, following symbolic links. It returns an error wrapping ErrNoXattr if
+// the attribute is not set.
+func GetXattr(path, name string) ([]byte, error) {
+	absPath, err := absolute(path)
+	if err != nil {
+		return nil, err
+	}
+	return getxattr(absPath, name)
+}
+
+// SetXattr sets the value of the extended attribute name of the file at path,
+// following symbolic links. The attribute is created if needed. On Linux,
+// attributes set by unprivileged users must be in the "user." namespace.
+func SetXattr(path, name string, value []byte) error {
+	absPath, err := absolute(path)
+	if err != nil {
+		return err
+	}
+	return setxattr(absPath, name, value)
+}
+
+// RemoveXattr removes the extended attribute name of the file at path,
+// following symbolic links. It returns an error wrapping ErrNoXattr if the
+// attribute is not set.
+func RemoveXattr(path, name string) error {
+	absPath, err := absolute(path)
+	if err != nil {
+		return err
+	}
+	return removexattr(absPath, name)
+}
+
+// ListXattr returns the sorted names of the extended attributes of the file
+// at path, following symbolic links. Only the attributes readable by the
+// caller are listed.
+func ListXattr(path string) ([]string, error) {
+	absPath, err := absolute(path)
+	if err != nil {
+		return nil, err
+	}
+	names, err := listxattr(absPath)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readXattrs returns the extended attributes of the file at path, by name.
+func readXattrs(path string) (map[string][]byte, error) {
+	names, err := listxattr(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := getxattr(path, name)
+		if errors.Is(err, ErrNoXattr) {
+			continue // removed since listed
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// splitXattrNames splits a list of NUL-terminated attribute names, as
+// returned by listxattr(2).
+func splitXattrNames(buf []byte) []string {
+	var names []string
+	for len(buf) > 0 {
+		end := bytes.IndexByte(buf, 0)
+		if end < 0 {
+			end = len(buf)
+		}
+		if end > 0 {
+			names = append(names, string(buf[:end]))
+		}
+		buf = buf[min(end+1, len(buf)):]
+	}
+	return names
+}
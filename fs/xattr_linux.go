@@ -0,0 +1,75 @@
+//go:build linux
+
+package fs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// This file provides access to extended attributes on Linux.
+
+// getxattr returns the value of the extended attribute name of the file at path.
+func getxattr(path, name string) ([]byte, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := unix.Getxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// The value grew since the size was queried; query it again.
+			if n, err = unix.Getxattr(path, name, nil); err == nil {
+				buf = make([]byte, n)
+				continue
+			}
+		}
+		if err != nil {
+			return nil, xattrError("getxattr", path, err)
+		}
+		return buf[:n], nil
+	}
+}
+
+// setxattr sets the value of the extended attribute name of the file at path.
+func setxattr(path, name string, value []byte) error {
+	if err := unix.Setxattr(path, name, value, 0); err != nil {
+		return xattrError("setxattr", path, err)
+	}
+	return nil
+}
+
+// removexattr removes the extended attribute name of the file at path.
+func removexattr(path, name string) error {
+	if err := unix.Removexattr(path, name); err != nil {
+		return xattrError("removexattr", path, err)
+	}
+	return nil
+}
+
+// listxattr returns the names of the extended attributes of the file at path.
+func listxattr(path string) ([]string, error) {
+	n, err := unix.Listxattr(path, nil)
+	for err == nil {
+		buf := make([]byte, n)
+		if n, err = unix.Listxattr(path, buf); err == nil {
+			return splitXattrNames(buf[:n]), nil
+		}
+		if errors.Is(err, unix.ERANGE) {
+			// The list grew since its size was queried; query it again.
+			n, err = unix.Listxattr(path, nil)
+		}
+	}
+	return nil, xattrError("listxattr", path, err)
+}
+
+// xattrError converts an error returned by an extended attribute system call
+// to the errors of the package.
+func xattrError(op, path string, err error) error {
+	switch {
+	case errors.Is(err, unix.ENOTSUP):
+		err = ErrXattrUnsupported
+	case errors.Is(err, unix.ENODATA):
+		err = ErrNoXattr
+	}
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
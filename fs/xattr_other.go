@@ -0,0 +1,28 @@
+//go:build !linux && !darwin
+
+package fs
+
+import "os"
+
+// This file provides the fallback for the systems without extended attribute
+// support.
+
+// getxattr always fails with ErrXattrUnsupported.
+func getxattr(path, name string) ([]byte, error) {
+	return nil, &os.PathError{Op: "getxattr", Path: path, Err: ErrXattrUnsupported}
+}
+
+// setxattr always fails with ErrXattrUnsupported.
+func setxattr(path, name string, value []byte) error {
+	return &os.PathError{Op: "setxattr", Path: path, Err: ErrXattrUnsupported}
+}
+
+// removexattr always fails with ErrXattrUnsupported.
+func removexattr(path, name string) error {
+	return &os.PathError{Op: "removexattr", Path: path, Err: ErrXattrUnsupported}
+}
+
+// listxattr always fails with ErrXattrUnsupported.
+func listxattr(path string) ([]string, error) {
+	return nil, &os.PathError{Op: "listxattr", Path: path, Err: ErrXattrUnsupported}
+}
@@ -0,0 +1,76 @@
+package fs
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXattr(t *testing.T) {
+	root := t.TempDir()
+	createTree(t, root, map[string]string{"a.jpg": "photo-a"})
+	path := filepath.Join(root, "a.jpg")
+
+	err := SetXattr(path, XattrOriginURL, []byte("https://example.com/a.jpg"))
+	if errors.Is(err, ErrXattrUnsupported) {
+		t.Skip("Skipping xattr test because extended attributes are not supported")
+	}
+	require.NoError(t, err)
+	require.NoError(t, SetXattr(path, XattrTags, []byte("holiday,beach")))
+
+	info, err := NewFileInfo(path)
+	require.NoError(t, err)
+
+	t.Run("get", func(t *testing.T) {
+		value, err := GetXattr(path, XattrOriginURL)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/a.jpg", string(value))
+
+		_, err = GetXattr(path, XattrComment)
+		assert.ErrorIs(t, err, ErrNoXattr)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		names, err := ListXattr(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{XattrOriginURL, XattrTags}, names)
+	})
+
+	t.Run("file info", func(t *testing.T) {
+		xattrs, err := info.Xattrs()
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]byte{
+			XattrOriginURL: []byte("https://example.com/a.jpg"),
+			XattrTags:      []byte("holiday,beach"),
+		}, xattrs)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, RemoveXattr(path, XattrOriginURL))
+		assert.ErrorIs(t, RemoveXattr(path, XattrOriginURL), ErrNoXattr)
+
+		names, err := ListXattr(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{XattrTags}, names)
+
+		// Attributes are cached by the FileInfo once loaded.
+		xattrs, err := info.Xattrs()
+		require.NoError(t, err)
+		assert.Len(t, xattrs, 2)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := GetXattr(filepath.Join(root, "missing.jpg"), XattrTags)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrNoXattr)
+	})
+}
+
+func TestSplitXattrNames(t *testing.T) {
+	assert.Equal(t, []string{"user.a", "user.b"}, splitXattrNames([]byte("user.a\x00user.b\x00")))
+	assert.Equal(t, []string{"user.a", "user.b"}, splitXattrNames([]byte("user.a\x00\x00user.b")))
+	assert.Empty(t, splitXattrNames(nil))
+}
@@ -0,0 +1,148 @@
+package fs
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// This file provides a pure Go implementation of the 64-bit xxHash algorithm
+// (XXH64), a fast non-cryptographic hash suitable for detecting changes and
+// duplicates, but not for resisting deliberate collisions.
+
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 is the streaming state of an XXH64 computation with a zero seed.
+type xxh64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64
+	buf            [32]byte
+	n              int // number of bytes in buf
+}
+
+// xxh64 should implement the hash.Hash64 interface
+var _ hash.Hash64 = (*xxh64)(nil)
+
+// newXXH64 returns a new hash.Hash64 computing the XXH64 checksum.
+func newXXH64() *xxh64 {
+	h := &xxh64{}
+	h.Reset()
+	return h
+}
+
+// Reset resets the hash to its initial state.
+func (h *xxh64) Reset() {
+	// The initial accumulators wrap around, which is not allowed for constants.
+	p1, p2 := xxhPrime1, xxhPrime2
+	h.v1 = p1 + p2
+	h.v2 = p2
+	h.v3 = 0
+	h.v4 = -p1
+	h.total = 0
+	h.n = 0
+}
+
+// Size returns the number of bytes Sum will return.
+func (h *xxh64) Size() int {
+	return 8
+}
+
+// BlockSize returns the hash's underlying block size.
+func (h *xxh64) BlockSize() int {
+	return 32
+}
+
+// Write adds more data to the running hash. It never returns an error.
+func (h *xxh64) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+
+	if h.n+len(p) < 32 {
+		h.n += copy(h.buf[h.n:], p)
+		return n, nil
+	}
+
+	if h.n > 0 {
+		c := copy(h.buf[h.n:], p)
+		p = p[c:]
+		h.stripe(h.buf[:])
+		h.n = 0
+	}
+	for len(p) >= 32 {
+		h.stripe(p[:32])
+		p = p[32:]
+	}
+	h.n = copy(h.buf[:], p)
+	return n, nil
+}
+
+// stripe consumes a 32-byte stripe.
+func (h *xxh64) stripe(b []byte) {
+	h.v1 = xxhRound(h.v1, binary.LittleEndian.Uint64(b[0:8]))
+	h.v2 = xxhRound(h.v2, binary.LittleEndian.Uint64(b[8:16]))
+	h.v3 = xxhRound(h.v3, binary.LittleEndian.Uint64(b[16:24]))
+	h.v4 = xxhRound(h.v4, binary.LittleEndian.Uint64(b[24:32]))
+}
+
+// Sum64 returns the current hash.
+func (h *xxh64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v1, 1) + bits.RotateLeft64(h.v2, 7) +
+			bits.RotateLeft64(h.v3, 12) + bits.RotateLeft64(h.v4, 18)
+		acc = xxhMergeRound(acc, h.v1)
+		acc = xxhMergeRound(acc, h.v2)
+		acc = xxhMergeRound(acc, h.v3)
+		acc = xxhMergeRound(acc, h.v4)
+	} else {
+		acc = xxhPrime5
+	}
+	acc += h.total
+
+	b := h.buf[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		acc ^= xxhRound(0, binary.LittleEndian.Uint64(b))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(b) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(b)) * xxhPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime2 + xxhPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		acc ^= uint64(c) * xxhPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxhPrime2
+	acc ^= acc >> 29
+	acc *= xxhPrime3
+	acc ^= acc >> 32
+	return acc
+}
+
+// Sum appends the current hash to b in big-endian order and returns the
+// resulting slice. It does not change the underlying hash state.
+func (h *xxh64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.Sum64())
+}
+
+// xxhRound mixes an 8-byte lane into an accumulator.
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime1
+}
+
+// xxhMergeRound merges an accumulator into the final hash.
+func xxhMergeRound(acc, val uint64) uint64 {
+	acc ^= xxhRound(0, val)
+	return acc*xxhPrime1 + xxhPrime4
+}
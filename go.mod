@@ -1,8 +1,11 @@
 module github.com/smartmediafiles/media.fs
 
-go 1.24
+go 1.24.0
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/stretchr/testify v1.10.0
+	golang.org/x/sys v0.38.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect